@@ -0,0 +1,95 @@
+// Command grantadmin gives an existing account the admin role, which is how
+// the first admin of a new deployment is made. Once there is an admin, more
+// are granted through the API; pass -force to grant one anyway, for example
+// when every admin has lost access.
+//
+//	go run ./cmd/grantadmin -email you@example.com
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+const roleAdmin = "admin"
+
+func main() {
+	godotenv.Load()
+
+	dbURL := flag.String("db", os.Getenv("DB_URL"), "Postgres connection string (defaults to $DB_URL)")
+	email := flag.String("email", "", "email address of the account to make an admin")
+	force := flag.Bool("force", false, "grant the role even though an admin already exists")
+	flag.Parse()
+
+	if *dbURL == "" {
+		log.Fatal("a database is required: set DB_URL or pass -db")
+	}
+	if *email == "" {
+		log.Fatal("pass the -email of the account to make an admin")
+	}
+
+	db, err := sql.Open("postgres", *dbURL)
+	if err != nil {
+		log.Fatalf("couldn't open the database: %s", err)
+	}
+	defer db.Close()
+
+	err = grantAdmin(context.Background(), db, *email, *force)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%s is now an admin; the role applies from their next login or refresh", *email)
+}
+
+func grantAdmin(ctx context.Context, db *sql.DB, email string, force bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := database.New(tx)
+
+	user, err := q.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("no account uses that email, sign up first")
+	}
+	if err != nil {
+		return err
+	}
+
+	// Taken so two runs at once can't both see no admin.
+	err = q.LockRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	admins, err := q.CountUsersWithRole(ctx, roleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins > 0 && !force {
+		return errors.New("there already is an admin, grant roles through the API or pass -force")
+	}
+
+	granted, err := q.GrantUserRole(ctx, database.GrantUserRoleParams{
+		UserID:    user.ID,
+		Role:      roleAdmin,
+		GrantedBy: uuid.NullUUID{},
+	})
+	if err != nil {
+		return err
+	}
+	if granted == 0 {
+		return errors.New("that account already is an admin")
+	}
+
+	return tx.Commit()
+}
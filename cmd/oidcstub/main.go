@@ -0,0 +1,59 @@
+// Command oidcstub runs a stand-in OpenID provider for trying single sign-on
+// locally. It signs in the account given by its flags without asking, so
+// point a development server at it:
+//
+//	go run ./cmd/oidcstub -email staff@example.com
+//	OIDC_ISSUER=http://localhost:9000 OIDC_CLIENT_ID=chirpy go run .
+//
+// then open http://localhost:8080/api/oidc/login in a browser.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rangaroo/chirpy-http-server/internal/oidc/oidctest"
+)
+
+func main() {
+	issuer := flag.String("issuer", "http://localhost:9000", "URL the provider is reached at, which is also its issuer")
+	clientID := flag.String("client-id", "chirpy", "client ID Chirpy is configured with")
+	clientSecret := flag.String("client-secret", "", "client secret Chirpy is configured with, if any")
+	redirectURL := flag.String("redirect-url", "http://localhost:8080/api/oidc/callback", "the only redirect URI accepted")
+	email := flag.String("email", "", "email address of the account to sign in")
+	subject := flag.String("sub", "", "subject of the account (defaults to the email address)")
+	name := flag.String("name", "", "display name of the account")
+	unverified := flag.Bool("unverified", false, "claim the email address isn't verified")
+	flag.Parse()
+
+	if *email == "" {
+		log.Fatal("pass the -email of the account to sign in")
+	}
+	if *subject == "" {
+		*subject = *email
+	}
+
+	u, err := url.Parse(*issuer)
+	if err != nil || u.Host == "" {
+		log.Fatalf("invalid issuer %q", *issuer)
+	}
+
+	idp, err := oidctest.New(*clientID, *clientSecret)
+	if err != nil {
+		log.Fatalf("couldn't create the provider: %s", err)
+	}
+	idp.Issuer = strings.TrimSuffix(*issuer, "/")
+	idp.RedirectURL = *redirectURL
+	idp.SetUser(oidctest.Identity{
+		Subject:       *subject,
+		Email:         *email,
+		EmailVerified: !*unverified,
+		Name:          *name,
+	})
+
+	log.Printf("signing in %s as %s at %s", *email, *subject, idp.Issuer)
+	log.Fatal(http.ListenAndServe(u.Host, idp))
+}
@@ -0,0 +1,153 @@
+// Command polkasim plays Polka, the payment provider, against a running
+// Chirpy server. It signs up fake customers and sends them signed webhook
+// sequences, optionally out of order and with redeliveries, then prints
+// each customer's membership as the server reports it.
+//
+//	go run ./cmd/polkasim -scenario lifecycle -customers 3 -shuffle
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"github.com/rangaroo/chirpy-http-server/internal/polka"
+)
+
+type customer struct {
+	ID       uuid.UUID `json:"id"`
+	Email    string    `json:"email"`
+	Password string    `json:"-"`
+}
+
+type membership struct {
+	IsChirpyRed    bool       `json:"is_chirpy_red"`
+	ChirpyRedUntil *time.Time `json:"chirpy_red_until"`
+}
+
+func main() {
+	godotenv.Load()
+
+	baseURL := flag.String("url", "http://localhost:8080", "base URL of the Chirpy server")
+	secret := flag.String("key", os.Getenv("POLKA_KEY"), "webhook signing secret (defaults to $POLKA_KEY)")
+	scenario := flag.String("scenario", "lifecycle", "event sequence to send: "+strings.Join(polka.ScenarioNames(), ", "))
+	plan := flag.String("plan", "red", "plan named in upgrade and renewal events")
+	customers := flag.Int("customers", 1, "number of fake customers to create")
+	shuffle := flag.Bool("shuffle", false, "deliver each customer's events in random order")
+	redeliver := flag.Float64("redeliver", 0, "probability of sending an acknowledged event again, as if the ack was lost")
+	delay := flag.Duration("delay", 0, "pause between deliveries")
+	attempts := flag.Int("attempts", 5, "delivery attempts per event before giving up")
+	seed := flag.Uint64("seed", uint64(time.Now().UnixNano()), "seed for shuffling and redeliveries")
+	flag.Parse()
+
+	if *secret == "" {
+		log.Fatal("a signing secret is required: set POLKA_KEY or pass -key")
+	}
+	if _, ok := polka.Scenarios[*scenario]; !ok {
+		log.Fatalf("unknown scenario %q, expected one of: %s", *scenario, strings.Join(polka.ScenarioNames(), ", "))
+	}
+
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(*seed, *seed))
+	client := polka.NewClient(strings.TrimSuffix(*baseURL, "/")+"/api/polka/webhooks", *secret)
+	client.MaxAttempts = *attempts
+	log.Printf("seed %d", *seed)
+
+	failed := false
+	for i := 0; i < *customers; i++ {
+		c, err := createCustomer(*baseURL)
+		if err != nil {
+			log.Fatalf("couldn't create a customer: %s", err)
+		}
+		log.Printf("customer %s (%s)", c.ID, c.Email)
+
+		events, err := polka.Scenario(*scenario, c.ID, *plan, time.Now())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *shuffle {
+			polka.Shuffle(events, rng)
+		}
+
+		for _, event := range events {
+			sends := 1
+			if rng.Float64() < *redeliver {
+				sends = 2
+			}
+			for ; sends > 0; sends-- {
+				delivery, err := client.Deliver(ctx, event)
+				log.Printf("  %-22s %s  attempts=%d status=%d", event.Event, event.ID, delivery.Attempts, delivery.StatusCode)
+				if err != nil {
+					log.Printf("  %s", err)
+					failed = true
+				}
+				time.Sleep(*delay)
+			}
+		}
+
+		m, err := fetchMembership(*baseURL, c)
+		if err != nil {
+			log.Printf("  couldn't read the membership back: %s", err)
+			failed = true
+			continue
+		}
+		until := "never"
+		if m.ChirpyRedUntil != nil {
+			until = m.ChirpyRedUntil.Format(time.RFC3339)
+		}
+		log.Printf("  is_chirpy_red=%t chirpy_red_until=%s", m.IsChirpyRed, until)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func createCustomer(baseURL string) (customer, error) {
+	c := customer{
+		Email:    fmt.Sprintf("customer-%s@polka.test", uuid.NewString()[:8]),
+		Password: uuid.NewString(),
+	}
+	err := postJSON(baseURL+"/api/users", map[string]string{
+		"email":    c.Email,
+		"password": c.Password,
+	}, http.StatusCreated, &c)
+	return c, err
+}
+
+// fetchMembership logs in as the customer, since that is the one place the
+// server hands out a user's own record.
+func fetchMembership(baseURL string, c customer) (membership, error) {
+	m := membership{}
+	err := postJSON(baseURL+"/api/login", map[string]string{
+		"email":    c.Email,
+		"password": c.Password,
+	}, http.StatusOK, &m)
+	return m, err
+}
+
+func postJSON(url string, payload any, wantStatus int, dst any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+)
+
+const (
+	authMethodJWT = "jwt"
+	authMethodPAT = "personal_access_token"
+	// authMethodOAuth is an access token issued to a third-party client.
+	authMethodOAuth = "oauth"
+
+	authRealm = "chirpy"
+)
+
+// principal is who a request acts for and what it may do.
+type principal struct {
+	UserID uuid.UUID
+	Method string
+	// Scopes only limit personal access tokens and OAuth clients.
+	Scopes []string
+	// Roles are only carried by logins.
+	Roles []string
+	// Token is the access token of a login or OAuth client, zero for
+	// personal access tokens.
+	Token auth.AccessToken
+}
+
+func (p principal) can(scope string) bool {
+	return p.Method == authMethodJWT || auth.HasScope(p.Scopes, scope)
+}
+
+type principalContextKey struct{}
+
+func withPrincipal(ctx context.Context, p principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+// principalFrom returns the caller that requireAuth or requireLogin found,
+// if any.
+func principalFrom(ctx context.Context) (principal, bool) {
+	p, ok := ctx.Value(principalContextKey{}).(principal)
+	return p, ok
+}
+
+// callerOf is principalFrom for handlers that can't be reached without
+// credentials. Mounting one without requireAuth is a bug, not a 401.
+func callerOf(req *http.Request) principal {
+	p, ok := principalFrom(req.Context())
+	if !ok {
+		panic(fmt.Sprintf("%s %s is served without requireAuth", req.Method, req.URL.Path))
+	}
+	return p
+}
+
+// authError is a failed authentication as RFC 6750 describes it. A zero
+// code means no credentials were sent at all.
+type authError struct {
+	status      int
+	code        string
+	description string
+	scope       string
+	err         error
+}
+
+func (e *authError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("%s: %s", e.description, e.err)
+	}
+	return e.description
+}
+
+func (e *authError) Unwrap() error {
+	return e.err
+}
+
+func errMissingCredentials() *authError {
+	return &authError{status: http.StatusUnauthorized, description: "Missing bearer token"}
+}
+
+func errInvalidRequest(err error) *authError {
+	return &authError{status: http.StatusBadRequest, code: "invalid_request", description: "Malformed Authorization header", err: err}
+}
+
+func errInvalidToken(err error) *authError {
+	return &authError{status: http.StatusUnauthorized, code: "invalid_token", description: "Invalid token", err: err}
+}
+
+func errInsufficientScope(scope, description string) *authError {
+	return &authError{status: http.StatusForbidden, code: "insufficient_scope", description: description, scope: scope}
+}
+
+// authenticate works out who the request is from. It accepts an access
+// token from a login or an OAuth client, or a personal access token. Errors other than
+// *authError come from the database.
+func (cfg *apiConfig) authenticate(req *http.Request) (principal, error) {
+	bearer, err := auth.GetBearerToken(req.Header)
+	if errors.Is(err, auth.ErrNoAuthHeader) {
+		return principal{}, errMissingCredentials()
+	}
+	if err != nil {
+		return principal{}, errInvalidRequest(err)
+	}
+
+	if auth.IsPersonalAccessToken(bearer) {
+		return cfg.authenticatePersonalAccessToken(req.Context(), bearer)
+	}
+
+	token, err := cfg.keyring.ParseAccessToken(bearer)
+	if err != nil {
+		return principal{}, errInvalidToken(err)
+	}
+	if token.ClientID != "" {
+		return principal{
+			UserID: token.UserID,
+			Method: authMethodOAuth,
+			Scopes: token.Scopes,
+			Token:  token,
+		}, nil
+	}
+	return principal{
+		UserID: token.UserID,
+		Method: authMethodJWT,
+		Roles:  token.Roles,
+		Token:  token,
+	}, nil
+}
+
+func (cfg *apiConfig) authenticatePersonalAccessToken(ctx context.Context, bearer string) (principal, error) {
+	token, err := cfg.db.GetPersonalAccessTokenByHash(ctx, auth.HashToken(bearer))
+	if errors.Is(err, sql.ErrNoRows) {
+		return principal{}, errInvalidToken(errors.New("unknown personal access token"))
+	}
+	if err != nil {
+		return principal{}, err
+	}
+	if token.RevokedAt.Valid {
+		return principal{}, errInvalidToken(errors.New("personal access token was revoked"))
+	}
+	if token.ExpiresAt.Valid && time.Now().After(token.ExpiresAt.Time) {
+		return principal{}, errInvalidToken(errors.New("personal access token has expired"))
+	}
+
+	// Only moved once a minute, so most requests don't write.
+	err = cfg.db.TouchPersonalAccessToken(ctx, token.ID)
+	if err != nil {
+		log.Printf("Couldn't record the use of personal access token %s: %s", token.ID, err)
+	}
+
+	return principal{
+		UserID: token.UserID,
+		Method: authMethodPAT,
+		Scopes: token.Scopes,
+	}, nil
+}
+
+// requireAuth lets a request through only with credentials that carry
+// scope, and hands the caller to next in the request context.
+func (cfg *apiConfig) requireAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return cfg.authorize(next, func(p principal) error {
+		if !p.can(scope) {
+			return errInsufficientScope(scope, "Token is missing the required scope")
+		}
+		return nil
+	})
+}
+
+// requireLogin is requireAuth for endpoints that manage the account's
+// credentials, which a personal access token must not reach whatever its
+// scopes.
+func (cfg *apiConfig) requireLogin(next http.HandlerFunc) http.HandlerFunc {
+	return cfg.authorize(next, func(p principal) error {
+		if p.Method != authMethodJWT {
+			return errInsufficientScope("", "This needs a login session")
+		}
+		return nil
+	})
+}
+
+func (cfg *apiConfig) authorize(next http.HandlerFunc, allowed func(principal) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		p, err := cfg.authenticate(req)
+		if err == nil {
+			err = allowed(p)
+		}
+		if err != nil {
+			respondWithAuthError(w, err)
+			return
+		}
+		next(w, req.WithContext(withPrincipal(req.Context(), p)))
+	}
+}
+
+// respondWithAuthError answers a failed authentication with the
+// WWW-Authenticate challenge from RFC 6750.
+func respondWithAuthError(w http.ResponseWriter, err error) {
+	var authErr *authError
+	if !errors.As(err, &authErr) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't authenticate", err)
+		return
+	}
+
+	challenge := fmt.Sprintf("Bearer realm=%q", authRealm)
+	if authErr.code != "" {
+		challenge += fmt.Sprintf(", error=%q, error_description=%q", authErr.code, authErr.description)
+	}
+	if authErr.scope != "" {
+		challenge += fmt.Sprintf(", scope=%q", authErr.scope)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	respondWithError(w, authErr.status, authErr.description, authErr.err)
+}
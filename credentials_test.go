@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+)
+
+// newAuthTestConfig is enough of a server to check tokens that don't need
+// the database: logins and OAuth clients.
+func newAuthTestConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	key, err := auth.GenerateSigningKey("test")
+	if err != nil {
+		t.Fatalf("couldn't generate a signing key: %v", err)
+	}
+	return &apiConfig{keyring: auth.NewKeyring(key)}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	cfg := newAuthTestConfig(t)
+	userID := uuid.New()
+
+	login, err := cfg.keyring.MakeJWT(userID, 0, nil, time.Hour)
+	if err != nil {
+		t.Fatalf("couldn't make a login token: %v", err)
+	}
+	readOnly, err := cfg.keyring.MakeClientJWT(userID, 0, uuid.NewString(), []string{auth.ScopeChirpsRead}, time.Hour)
+	if err != nil {
+		t.Fatalf("couldn't make a client token: %v", err)
+	}
+	expired, err := cfg.keyring.MakeJWT(userID, 0, nil, -time.Minute)
+	if err != nil {
+		t.Fatalf("couldn't make an expired token: %v", err)
+	}
+
+	type wrapper func(http.HandlerFunc) http.HandlerFunc
+	requireWrite := func(next http.HandlerFunc) http.HandlerFunc {
+		return cfg.requireAuth(auth.ScopeChirpsWrite, next)
+	}
+
+	tests := []struct {
+		name          string
+		wrap          wrapper
+		authorization string
+		wantStatus    int
+		wantChallenge string
+		wantCaller    bool
+	}{
+		{
+			name:          "missing token",
+			wrap:          requireWrite,
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="chirpy"`,
+		},
+		{
+			name:          "malformed header",
+			wrap:          requireWrite,
+			authorization: "Basic dXNlcjpwYXNz",
+			wantStatus:    http.StatusBadRequest,
+			wantChallenge: `Bearer realm="chirpy", error="invalid_request", error_description="Malformed Authorization header"`,
+		},
+		{
+			name:          "invalid token",
+			wrap:          requireWrite,
+			authorization: "Bearer not-a-token",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="chirpy", error="invalid_token", error_description="Invalid token"`,
+		},
+		{
+			name:          "expired token",
+			wrap:          requireWrite,
+			authorization: "Bearer " + expired,
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: `Bearer realm="chirpy", error="invalid_token", error_description="Invalid token"`,
+		},
+		{
+			name:          "insufficient scope",
+			wrap:          requireWrite,
+			authorization: "Bearer " + readOnly,
+			wantStatus:    http.StatusForbidden,
+			wantChallenge: `Bearer realm="chirpy", error="insufficient_scope", error_description="Token is missing the required scope", scope="chirps:write"`,
+		},
+		{
+			name:          "login has every scope",
+			wrap:          requireWrite,
+			authorization: "Bearer " + login,
+			wantStatus:    http.StatusOK,
+			wantCaller:    true,
+		},
+		{
+			name:          "client token where a login is needed",
+			wrap:          cfg.requireLogin,
+			authorization: "Bearer " + readOnly,
+			wantStatus:    http.StatusForbidden,
+			wantChallenge: `Bearer realm="chirpy", error="insufficient_scope", error_description="This needs a login session"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotCaller := false
+			handler := tt.wrap(func(w http.ResponseWriter, req *http.Request) {
+				p, ok := principalFrom(req.Context())
+				gotCaller = ok && p.UserID == userID
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/chirps", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.wantChallenge)
+			}
+			if gotCaller != tt.wantCaller {
+				t.Errorf("caller in context = %t, want %t", gotCaller, tt.wantCaller)
+			}
+		})
+	}
+}
+
+func TestRespondWithAuthErrorHidesOtherErrors(t *testing.T) {
+	w := httptest.NewRecorder()
+	respondWithAuthError(w, http.ErrHandlerTimeout)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if got := w.Header().Get("WWW-Authenticate"); got != "" {
+		t.Fatalf("WWW-Authenticate = %q on a server error, want none", got)
+	}
+}
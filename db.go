@@ -0,0 +1,14 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// isUniqueViolation reports whether err comes from a unique constraint,
+// such as a second account claiming the same email.
+func isUniqueViolation(err error) bool {
+	pqErr := &pq.Error{}
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
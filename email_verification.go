@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/mailer"
+)
+
+const emailVerificationTTL = 48 * time.Hour
+
+var errEmailNotVerified = errors.New("email address is not verified")
+
+// sendVerificationEmail queues a link that proves the user owns address.
+// It goes through q so the token and the email are only kept if the
+// surrounding transaction commits.
+func (cfg *apiConfig) sendVerificationEmail(ctx context.Context, q *database.Queries, userID uuid.UUID, address string) error {
+	token, err := auth.MakeToken()
+	if err != nil {
+		return err
+	}
+
+	err = q.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Email:     address,
+		ExpiresAt: time.Now().UTC().Add(emailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := cfg.publicURL + "/app/verify-email?token=" + url.QueryEscape(token)
+	return enqueueEmail(ctx, q, mailer.Message{
+		To:      address,
+		Subject: "Confirm your email address for Chirpy",
+		Body: fmt.Sprintf("Please confirm that %s is your email address by opening this link "+
+			"within the next two days:\n\n%s\n\n"+
+			"If you didn't sign up for Chirpy, you can ignore this email.\n", address, link),
+	})
+}
+
+// checkCanChirp applies the policy for unverified accounts.
+func (cfg *apiConfig) checkCanChirp(ctx context.Context, userID uuid.UUID) error {
+	if cfg.unverifiedOK {
+		return nil
+	}
+	user, err := cfg.db.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.EmailVerifiedAt.Valid {
+		return errEmailNotVerified
+	}
+	return nil
+}
+
+var (
+	errInvalidVerificationToken = errors.New("invalid or expired verification token")
+	errEmailTaken               = errors.New("email is already taken")
+)
+
+// handlerEmailVerificationConfirm marks an address as verified. For an
+// email change this is the moment the new address replaces the old one.
+func (cfg *apiConfig) handlerEmailVerificationConfirm(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	type returnVals struct {
+		User
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.confirmEmail(req.Context(), params.Token)
+	if errors.Is(err, errInvalidVerificationToken) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired verification token", err)
+		return
+	}
+	if errors.Is(err, errEmailTaken) {
+		respondWithError(w, http.StatusConflict, "Email is already taken", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't verify the email", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		User: databaseUserToUser(user),
+	})
+}
+
+// confirmEmail spends a verification token and makes its address the
+// user's confirmed email.
+func (cfg *apiConfig) confirmEmail(ctx context.Context, tokenString string) (database.User, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	token, err := qtx.GetEmailVerificationTokenForUpdate(ctx, auth.HashToken(tokenString))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+	if errors.Is(err, sql.ErrNoRows) || token.UsedAt.Valid || time.Now().After(token.ExpiresAt) {
+		return database.User{}, errInvalidVerificationToken
+	}
+
+	user, err := qtx.GetUser(ctx, token.UserID)
+	if err != nil {
+		return database.User{}, err
+	}
+	// A link for an address the user has since moved away from is stale.
+	if token.Email != user.Email && token.Email != user.PendingEmail.String {
+		return database.User{}, errInvalidVerificationToken
+	}
+
+	user, err = qtx.ConfirmUserEmail(ctx, database.ConfirmUserEmailParams{
+		ID:    user.ID,
+		Email: token.Email,
+	})
+	if isUniqueViolation(err) {
+		return database.User{}, errEmailTaken
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	err = qtx.UseEmailVerificationTokens(ctx, user.ID)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return database.User{}, err
+	}
+
+	cfg.publish(eventUserUpdated, user.ID, databaseUserToUser(user))
+	return user, nil
+}
+
+type verifyEmailPage struct {
+	Token string
+	Email string
+	Error string
+}
+
+var verifyEmailTemplate = template.Must(template.New("verify-email").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Confirm your email - Chirpy</title>
+  <style>
+    body { font-family: sans-serif; max-width: 28rem; margin: 3rem auto; padding: 0 1rem; }
+    .error { color: #b00020; }
+    .buttons { margin-top: 1.5rem; }
+  </style>
+</head>
+<body>
+  <h1>Confirm your email</h1>
+{{if .Email}}
+  <p><strong>{{.Email}}</strong> is now the confirmed email address of your Chirpy account.</p>
+{{else if .Token}}
+  {{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+  <form method="post" action="/app/verify-email">
+    <input type="hidden" name="token" value="{{.Token}}">
+    <div class="buttons"><button type="submit">Confirm</button></div>
+  </form>
+{{else}}
+  <p class="error">{{.Error}}</p>
+{{end}}
+</body>
+</html>
+`))
+
+// handlerVerifyEmailPage is where the link in a verification email leads.
+// It only asks for a click, since mail scanners open links on their own and
+// would otherwise confirm addresses for whoever typed them in.
+func (cfg *apiConfig) handlerVerifyEmailPage(w http.ResponseWriter, req *http.Request) {
+	page := verifyEmailPage{Token: req.URL.Query().Get("token")}
+	if page.Token == "" {
+		page.Error = "This confirmation link is incomplete. Copy the whole link from the email."
+		renderPage(w, http.StatusBadRequest, verifyEmailTemplate, page)
+		return
+	}
+	renderPage(w, http.StatusOK, verifyEmailTemplate, page)
+}
+
+// handlerVerifyEmailForm takes the form of the confirmation page.
+func (cfg *apiConfig) handlerVerifyEmailForm(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		renderPage(w, http.StatusBadRequest, verifyEmailTemplate, verifyEmailPage{Error: "Couldn't read the form."})
+		return
+	}
+	page := verifyEmailPage{Token: req.PostForm.Get("token")}
+
+	user, err := cfg.confirmEmail(req.Context(), page.Token)
+	if errors.Is(err, errInvalidVerificationToken) {
+		page = verifyEmailPage{Error: "This confirmation link is invalid or has expired. Ask for a new one."}
+		renderPage(w, http.StatusBadRequest, verifyEmailTemplate, page)
+		return
+	}
+	if errors.Is(err, errEmailTaken) {
+		page = verifyEmailPage{Error: "Another account has confirmed this address in the meantime."}
+		renderPage(w, http.StatusConflict, verifyEmailTemplate, page)
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't verify the email: %s", err)
+		page.Error = "Something went wrong, try again later."
+		renderPage(w, http.StatusInternalServerError, verifyEmailTemplate, page)
+		return
+	}
+
+	renderPage(w, http.StatusOK, verifyEmailTemplate, verifyEmailPage{Email: user.Email})
+}
+
+// handlerEmailVerificationResend sends a fresh link for whichever address
+// is still waiting to be confirmed.
+func (cfg *apiConfig) handlerEmailVerificationResend(w http.ResponseWriter, req *http.Request) {
+	userID := callerOf(req).UserID
+
+	user, err := cfg.db.GetUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	address := user.PendingEmail.String
+	if address == "" {
+		if user.EmailVerifiedAt.Valid {
+			respondWithError(w, http.StatusConflict, "Email is already verified", nil)
+			return
+		}
+		address = user.Email
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send the verification email", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = cfg.sendVerificationEmail(req.Context(), qtx, user.ID, address)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send the verification email", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send the verification email", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
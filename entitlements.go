@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/entitlements"
+)
+
+// planFor returns the plan whose limits apply to a user right now. It reads
+// chirpy_red_until rather than is_chirpy_red so a lapsed membership stops
+// counting before the sweeper gets to it.
+func (cfg *apiConfig) planFor(ctx context.Context, userID uuid.UUID) (entitlements.Plan, error) {
+	row, err := cfg.db.GetUserPlan(ctx, userID)
+	if err != nil {
+		return entitlements.Plan{}, err
+	}
+	active := row.ChirpyRedUntil.Valid && row.ChirpyRedUntil.Time.After(time.Now())
+	return cfg.entitlements.Resolve(row.Plan.String, active), nil
+}
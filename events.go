@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	eventChirpCreated = "chirp.created"
+	eventChirpDeleted = "chirp.deleted"
+	eventUserCreated  = "user.created"
+	eventUserUpdated  = "user.updated"
+	eventTokenRevoked = "token.revoked"
+)
+
+// publish sends an event to every instance. The write it describes has
+// already happened, so a failure is logged rather than failing the request.
+func (cfg *apiConfig) publish(eventType string, key uuid.UUID, data any) {
+	_, err := cfg.events.Publish(eventType, key.String(), data)
+	if err != nil {
+		log.Printf("Couldn't publish %s: %s", eventType, err)
+	}
+}
+
+// tokenRevokedEvent carries an access token revocation to the other
+// instances; see announceRevocation.
+type tokenRevokedEvent struct {
+	UserID     uuid.UUID     `json:"user_id"`
+	TokenID    uuid.NullUUID `json:"token_id"`
+	MinVersion *int32        `json:"min_version,omitempty"`
+	ExpiresAt  time.Time     `json:"expires_at"`
+}
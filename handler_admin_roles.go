@@ -0,0 +1,184 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+func (cfg *apiConfig) handlerAdminRolesList(w http.ResponseWriter, req *http.Request) {
+	roles, err := cfg.db.ListRoles(req.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list roles", err)
+		return
+	}
+
+	result := make([]Role, 0, len(roles))
+	for _, role := range roles {
+		perms, err := cfg.db.ListRolePermissions(req.Context(), []string{role.Name})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't list roles", err)
+			return
+		}
+		if perms == nil {
+			perms = []string{}
+		}
+		result = append(result, Role{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: perms,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+func (cfg *apiConfig) handlerAdminUserRolesList(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	_, err = cfg.db.GetUser(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	roles, err := cfg.db.ListUserRoles(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list roles", err)
+		return
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+
+	respondWithJSON(w, http.StatusOK, roles)
+}
+
+// handlerAdminUserRoleGrant gives a user a role. It shows up in their access
+// tokens from their next refresh on.
+func (cfg *apiConfig) handlerAdminUserRoleGrant(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	role, err := cfg.db.GetRole(req.Context(), req.PathValue("role"))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find role", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get role", err)
+		return
+	}
+
+	_, err = cfg.db.GetUser(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	_, err = cfg.db.GrantUserRole(req.Context(), database.GrantUserRoleParams{
+		UserID:    userID,
+		Role:      role.Name,
+		GrantedBy: uuid.NullUUID{UUID: callerOf(req).UserID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't grant the role", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerAdminUserRoleRevoke takes a role away. The user's access tokens
+// are revoked along with it, since they still claim the role. The last
+// admin can't be removed, or nobody could grant roles any more.
+func (cfg *apiConfig) handlerAdminUserRoleRevoke(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+	role := req.PathValue("role")
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the role", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Two admins removing each other at once must not both succeed.
+	if role == roleAdmin {
+		err = qtx.LockRole(req.Context(), roleAdmin)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the role", err)
+			return
+		}
+	}
+
+	revoked, err := qtx.RevokeUserRole(req.Context(), database.RevokeUserRoleParams{
+		UserID: userID,
+		Role:   role,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the role", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "User doesn't have that role", nil)
+		return
+	}
+
+	if role == roleAdmin {
+		admins, err := qtx.CountUsersWithRole(req.Context(), roleAdmin)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the role", err)
+			return
+		}
+		if admins == 0 {
+			respondWithError(w, http.StatusConflict, "Can't remove the last admin", nil)
+			return
+		}
+	}
+
+	revocation, err := revokeUserTokens(req.Context(), qtx, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the role", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the role", err)
+		return
+	}
+
+	cfg.announceRevocation(revocation)
+
+	w.WriteHeader(http.StatusNoContent)
+}
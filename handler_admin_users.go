@@ -0,0 +1,95 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// handlerAdminUnlockUser lifts an account lockout ahead of time. Locks on
+// the addresses involved are left alone.
+func (cfg *apiConfig) handlerAdminUnlockUser(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	user, err := cfg.db.GetUser(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	err = cfg.db.ClearLoginThrottle(req.Context(), accountThrottleKey(user.Email))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlock the account", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerAdminRevokeUserTokens cuts off every access token the user holds,
+// for example when banning them. Their refresh tokens, personal access
+// tokens and the refresh tokens of apps they authorized are revoked as well
+// so they can't simply get new ones.
+func (cfg *apiConfig) handlerAdminRevokeUserTokens(w http.ResponseWriter, req *http.Request) {
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the tokens", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	revocation, err := revokeUserTokens(req.Context(), qtx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the tokens", err)
+		return
+	}
+
+	err = qtx.RevokeUserRefreshTokens(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the tokens", err)
+		return
+	}
+
+	err = qtx.RevokeUserPersonalAccessTokens(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the tokens", err)
+		return
+	}
+
+	err = qtx.RevokeUserOAuthRefreshTokens(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the tokens", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the tokens", err)
+		return
+	}
+
+	cfg.announceRevocation(revocation)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+type WebhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+	Attempts    int32           `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+}
+
+func databaseWebhookEventToWebhookEvent(event database.WebhookEvent) WebhookEvent {
+	resp := WebhookEvent{
+		ID:         event.ID,
+		EventID:    event.EventID,
+		EventType:  event.EventType,
+		Payload:    event.Payload,
+		ReceivedAt: event.ReceivedAt,
+		Attempts:   event.Attempts,
+		LastError:  event.LastError.String,
+	}
+	if event.ProcessedAt.Valid {
+		resp.ProcessedAt = &event.ProcessedAt.Time
+	}
+	return resp
+}
+
+// handlerAdminWebhooksList pages through stored webhook events, newest
+// first unless sort=asc is given.
+func (cfg *apiConfig) handlerAdminWebhooksList(w http.ResponseWriter, req *http.Request) {
+	page, err := parsePageParams(req.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if req.URL.Query().Get("sort") == "" {
+		page.Desc = true
+	}
+
+	cursorReceivedAt, cursorID := page.cursorArgs()
+	var events []database.WebhookEvent
+	if page.queryDesc() {
+		events, err = cfg.db.ListWebhookEvents(req.Context(), database.ListWebhookEventsParams{
+			CursorReceivedAt: cursorReceivedAt,
+			CursorID:         cursorID,
+			Limit:            page.queryLimit(),
+		})
+	} else {
+		events, err = cfg.db.ListWebhookEventsReverse(req.Context(), database.ListWebhookEventsReverseParams{
+			CursorReceivedAt: cursorReceivedAt,
+			CursorID:         cursorID,
+			Limit:            page.queryLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list webhook events", err)
+		return
+	}
+
+	events, next, prev := paginate(events, page, func(event database.WebhookEvent) pageCursor {
+		return pageCursor{CreatedAt: event.ReceivedAt, ID: event.ID}
+	})
+
+	resp := []WebhookEvent{}
+	for _, event := range events {
+		resp = append(resp, databaseWebhookEventToWebhookEvent(event))
+	}
+
+	setPageLinks(w, req, next, prev)
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// handlerAdminWebhooksReplay runs a stored event that hasn't been applied
+// yet, e.g. one that failed because the user didn't exist at the time.
+func (cfg *apiConfig) handlerAdminWebhooksReplay(w http.ResponseWriter, req *http.Request) {
+	webhookID, err := uuid.Parse(req.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse the webhookID", err)
+		return
+	}
+
+	event, err := cfg.db.GetWebhookEvent(req.Context(), webhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find the webhook event", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get the webhook event", err)
+		return
+	}
+
+	err = cfg.processWebhookEvent(req.Context(), event.EventID)
+	if errors.Is(err, errWebhookProcessed) {
+		respondWithError(w, http.StatusConflict, "Event has already been processed", err)
+		return
+	}
+	if err != nil && !errors.Is(err, errPolkaUserNotFound) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't process the event", err)
+		return
+	}
+
+	// A failed replay is reported through last_error like any other attempt.
+	event, err = cfg.db.GetWebhookEvent(req.Context(), webhookID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get the webhook event", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, databaseWebhookEventToWebhookEvent(event))
+}
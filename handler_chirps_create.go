@@ -2,25 +2,56 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 	"github.com/google/uuid"
 	"github.com/rangaroo/chirpy-http-server/internal/database"
-	"github.com/rangaroo/chirpy-http-server/internal/auth"
 )
 
 type Chirp struct {
-    ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID           uuid.UUID  `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	Body         string     `json:"body"`
+	UserID       uuid.UUID  `json:"user_id"`
+	ParentID     *uuid.UUID `json:"parent_id,omitempty"`
+	ReplyCount   int32      `json:"reply_count"`
+	LikeCount    int32      `json:"like_count"`
+	RechirpCount int32      `json:"rechirp_count"`
+	RechirpOfID  *uuid.UUID `json:"rechirp_of_id,omitempty"`
+	RechirpOf    *Chirp     `json:"rechirp_of,omitempty"`
+	Edited       bool       `json:"edited"`
+	Deleted      bool       `json:"deleted,omitempty"`
+}
+
+func databaseChirpToChirp(chirp database.Chirp) Chirp {
+	c := Chirp{
+		ID:           chirp.ID,
+		CreatedAt:    chirp.CreatedAt,
+		UpdatedAt:    chirp.UpdatedAt,
+		Body:         chirp.Body,
+		UserID:       chirp.UserID,
+		ReplyCount:   chirp.ReplyCount,
+		LikeCount:    chirp.LikeCount,
+		RechirpCount: chirp.RechirpCount,
+		Edited:       chirp.EditedAt.Valid,
+		Deleted:      chirp.DeletedAt.Valid,
+	}
+	if chirp.ParentID.Valid {
+		c.ParentID = &chirp.ParentID.UUID
+	}
+	if chirp.RechirpOfID.Valid {
+		c.RechirpOfID = &chirp.RechirpOfID.UUID
+	}
+	return c
 }
 
 func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, req *http.Request) {
 	type parameters struct {
-		Body   string      `json:"body"`
+		Body     string     `json:"body"`
+		ParentID *uuid.UUID `json:"parent_id"`
 	}
 
 	type returnVals struct {
@@ -36,53 +67,122 @@ func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
-	// Check if tokenString is valid
-	tokenString, err := auth.GetBearerToken(req.Header)
+	userID := callerOf(req).UserID
+
+	err = cfg.checkCanChirp(req.Context(), userID)
+	if errors.Is(err, errEmailNotVerified) {
+		respondWithError(w, http.StatusForbidden, "Verify your email address before chirping", err)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could't parse the token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check your account", err)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(tokenString, cfg.tokenSecret)
+	plan, err := cfg.planFor(req.Context(), userID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get your plan", err)
 		return
 	}
 
-	// Check for length
-	const maxChirpLength = 140
-	if len(params.Body) > maxChirpLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+	cleaned, err := prepareChirpBody(params.Body, plan.MaxChirpLength)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long", err)
 		return
 	}
 
-	badWords := map[string]struct{} {
-		"kerfuffle": {},
-		"sharbert":  {},
-		"fornax":    {},
+	// Rechirps don't use up the hourly allowance.
+	if plan.ChirpsPerHour > 0 {
+		count, err := cfg.db.CountChirpsByAuthorSince(req.Context(), database.CountChirpsByAuthorSinceParams{
+			UserID:    userID,
+			CreatedAt: time.Now().UTC().Add(-time.Hour),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+			return
+		}
+		if count >= int64(plan.ChirpsPerHour) {
+			respondWithError(w, http.StatusTooManyRequests, "Hourly chirp limit reached", nil)
+			return
+		}
+	}
+
+	parentID := uuid.NullUUID{}
+	if params.ParentID != nil {
+		parentID = uuid.NullUUID{UUID: *params.ParentID, Valid: true}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
 	}
-	cleaned := getCleanedBody(params.Body, badWords)
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
-	chirp, err := cfg.db.CreateChirp(req.Context(), database.CreateChirpParams{
-		Body:   cleaned,
-		UserID: userID,
+	if parentID.Valid {
+		// Replying to a rechirp is replying to the chirp it shares. The
+		// parent stays locked so a concurrent delete sees this reply.
+		parent, err := lockChirpTarget(req.Context(), qtx, parentID.UUID)
+		if errors.Is(err, errChirpNotFound) {
+			respondWithError(w, http.StatusNotFound, "Couldn't find the chirp to reply to", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get the chirp to reply to", err)
+			return
+		}
+		parentID.UUID = parent.ID
+
+		err = qtx.IncrementReplyCount(req.Context(), parent.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+			return
+		}
+	}
+
+	chirp, err := qtx.CreateChirp(req.Context(), database.CreateChirpParams{
+		Body:     cleaned,
+		UserID:   userID,
+		ParentID: parentID,
 	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could't create chirp", err)
 		return
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
+	}
+
+	resp := databaseChirpToChirp(chirp)
+	cfg.publish(eventChirpCreated, chirp.UserID, resp)
+
 	respondWithJSON(w, http.StatusCreated, returnVals{
-		Chirp: Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		},
+		Chirp: resp,
 	})
 }
 
+var errChirpTooLong = errors.New("chirp is too long")
+
+// prepareChirpBody applies the checks every chirp body goes through, whether
+// it is being created or edited. maxLength comes from the author's plan.
+func prepareChirpBody(body string, maxLength int) (string, error) {
+	// Check for length
+	if len(body) > maxLength {
+		return "", errChirpTooLong
+	}
+
+	badWords := map[string]struct{} {
+		"kerfuffle": {},
+		"sharbert":  {},
+		"fornax":    {},
+	}
+	return getCleanedBody(body, badWords), nil
+}
+
 func getCleanedBody(body string, badWords map[string]struct{}) string {
 	words := strings.Split(body, " ")
 	for i, word := range words {
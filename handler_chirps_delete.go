@@ -1,10 +1,10 @@
 package main
 
 import (
+	"log"
 	"net/http"
 
 	"github.com/google/uuid"
-	"github.com/rangaroo/chirpy-http-server/internal/auth"
 )
 
 func (cfg *apiConfig) handlerChirpsDelete(w http.ResponseWriter, req *http.Request) {
@@ -15,34 +15,71 @@ func (cfg *apiConfig) handlerChirpsDelete(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
-	accessToken, err := auth.GetBearerToken(req.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Could't parse the token", err)
-		return
-	}
+	caller := callerOf(req)
 
-	userID, err := auth.ValidateJWT(accessToken, cfg.tokenSecret)
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
-	chirp, err := cfg.db.GetChirp(req.Context(), chirpID)
-	if err != nil {
+	// Locked so a reply can't arrive between checking for replies and
+	// deleting.
+	chirp, err := qtx.GetChirpForUpdate(req.Context(), chirpID)
+	if err != nil || chirp.DeletedAt.Valid {
 		respondWithError(w, http.StatusNotFound, "Could't get chirp", err)
 		return
 	}
 
-	if userID != chirp.UserID {
-		respondWithError(w, http.StatusForbidden, "You can not delete other's chirps", err)
-		return
+	// Moderators and admins can take down anyone's chirp.
+	if caller.UserID != chirp.UserID {
+		allowed, err := cfg.hasPermission(req.Context(), caller, permChirpsModerate)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
+			return
+		}
+		if !allowed {
+			respondWithError(w, http.StatusForbidden, "You can not delete other's chirps", nil)
+			return
+		}
+		log.Printf("User %s removed chirp %s by %s", caller.UserID, chirp.ID, chirp.UserID)
 	}
 
-	err = cfg.db.DeleteChirp(req.Context(), chirpID)
+	// A chirp with replies is blanked out rather than removed so the
+	// conversation below it keeps its place in the thread. Its earlier
+	// bodies go with it.
+	if chirp.ReplyCount > 0 {
+		err = qtx.TombstoneChirp(req.Context(), chirpID)
+		if err == nil {
+			err = qtx.DeleteChirpRevisions(req.Context(), chirpID)
+		}
+	} else {
+		err = qtx.DeleteChirp(req.Context(), chirpID)
+		if err == nil && chirp.ParentID.Valid {
+			err = qtx.DecrementReplyCount(req.Context(), chirp.ParentID.UUID)
+		}
+		if err == nil && chirp.RechirpOfID.Valid {
+			err = qtx.DecrementRechirpCount(req.Context(), chirp.RechirpOfID.UUID)
+		}
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could't delete chirp", err)
 		return
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
+		return
+	}
+
+	cfg.publish(eventChirpDeleted, chirp.UserID, struct {
+		ID uuid.UUID `json:"id"`
+	}{
+		ID: chirp.ID,
+	})
+
 	w.WriteHeader(http.StatusNoContent)
 }
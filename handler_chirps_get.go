@@ -2,50 +2,66 @@ package main
 
 import (
 	"net/http"
+
 	"github.com/google/uuid"
-	"sort"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
 )
 
 func (cfg *apiConfig) handlerChirpsRetrieve(w http.ResponseWriter, req *http.Request) {
-	chirps, err := cfg.db.GetChirps(req.Context())
+	page, err := parsePageParams(req.URL.Query())
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could't get chirps", err)
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
 		return
 	}
 
-	authorID := uuid.Nil
+	authorID := uuid.NullUUID{}
 	authorIDString := req.URL.Query().Get("author_id")
 	if authorIDString != "" {
-		authorID, err = uuid.Parse(authorIDString)
+		authorID.UUID, err = uuid.Parse(authorIDString)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
 			return
 		}
+		authorID.Valid = true
 	}
 
-	responseChirps := []Chirp{}
-	for _, chirp := range chirps {
-		if authorID != uuid.Nil && authorID != chirp.UserID {
-			continue
-		}
-
-		responseChirps = append(responseChirps, Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	var chirps []database.Chirp
+	if page.queryDesc() {
+		chirps, err = cfg.db.ListChirpsDesc(req.Context(), database.ListChirpsDescParams{
+			AuthorID:        authorID,
+			Since:           page.Since,
+			Until:           page.Until,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.queryLimit(),
+		})
+	} else {
+		chirps, err = cfg.db.ListChirpsAsc(req.Context(), database.ListChirpsAscParams{
+			AuthorID:        authorID,
+			Since:           page.Since,
+			Until:           page.Until,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.queryLimit(),
 		})
 	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
+		return
+	}
 
-	sortMethod := req.URL.Query().Get("sort")
-	sort.Slice(responseChirps, func(i, j int) bool { 
-		if sortMethod == "desc" {
-		  return responseChirps[i].CreatedAt.After(responseChirps[j].CreatedAt)
-		}
-		return responseChirps[i].CreatedAt.Before(responseChirps[j].CreatedAt)
+	chirps, next, prev := paginate(chirps, page, func(chirp database.Chirp) pageCursor {
+		return pageCursor{CreatedAt: chirp.CreatedAt, ID: chirp.ID}
 	})
 
+	responseChirps, err := cfg.chirpsResponse(req.Context(), chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps", err)
+		return
+	}
+
+	setPageLinks(w, req, next, prev)
 	respondWithJSON(w, http.StatusOK, responseChirps)
 }
 
@@ -63,11 +79,11 @@ func (cfg *apiConfig) handlerChirpsGet(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
-	})
+	responseChirps, err := cfg.chirpsResponse(req.Context(), []database.Chirp{chirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, responseChirps[0])
 }
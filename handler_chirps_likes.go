@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+type Like struct {
+	UserID  uuid.UUID `json:"user_id"`
+	LikedAt time.Time `json:"liked_at"`
+}
+
+func (cfg *apiConfig) handlerChirpsLike(w http.ResponseWriter, req *http.Request) {
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse the chirpID", err)
+		return
+	}
+
+	userID := callerOf(req).UserID
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	chirp, err := resolveChirpTarget(req.Context(), qtx, chirpID)
+	if errors.Is(err, errChirpNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+
+	// The counter only moves when the like is new, so repeating the request
+	// is harmless.
+	inserted, err := qtx.LikeChirp(req.Context(), database.LikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	})
+	if err == nil && inserted > 0 {
+		err = qtx.IncrementLikeCount(req.Context(), chirp.ID)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerChirpsUnlike(w http.ResponseWriter, req *http.Request) {
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse the chirpID", err)
+		return
+	}
+
+	userID := callerOf(req).UserID
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlike chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Likes land on the original, so unliking through a rechirp has to
+	// find it the same way.
+	chirp, err := resolveChirpTarget(req.Context(), qtx, chirpID)
+	if errors.Is(err, errChirpNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+
+	deleted, err := qtx.UnlikeChirp(req.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	})
+	if err == nil && deleted > 0 {
+		err = qtx.DecrementLikeCount(req.Context(), chirp.ID)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlike chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unlike chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerChirpsLikesList(w http.ResponseWriter, req *http.Request) {
+	type returnVals struct {
+		Count int32  `json:"count"`
+		Users []Like `json:"users"`
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse the chirpID", err)
+		return
+	}
+
+	page, err := parsePageParams(req.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	page.Desc = true
+
+	chirp, err := resolveChirpTarget(req.Context(), cfg.db, chirpID)
+	if errors.Is(err, errChirpNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	params := database.ListChirpLikesParams{
+		ChirpID:         chirp.ID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           page.queryLimit(),
+	}
+
+	var rows []database.ListChirpLikesRow
+	if page.queryDesc() {
+		rows, err = cfg.db.ListChirpLikes(req.Context(), params)
+	} else {
+		var reversed []database.ListChirpLikesReverseRow
+		reversed, err = cfg.db.ListChirpLikesReverse(req.Context(), database.ListChirpLikesReverseParams(params))
+		for _, row := range reversed {
+			rows = append(rows, database.ListChirpLikesRow(row))
+		}
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list likes", err)
+		return
+	}
+
+	rows, next, prev := paginate(rows, page, func(row database.ListChirpLikesRow) pageCursor {
+		return pageCursor{CreatedAt: row.LikedAt, ID: row.UserID}
+	})
+
+	resp := returnVals{
+		Count: chirp.LikeCount,
+		Users: []Like{},
+	}
+	for _, row := range rows {
+		resp.Users = append(resp.Users, Like{
+			UserID:  row.UserID,
+			LikedAt: row.LikedAt,
+		})
+	}
+
+	setPageLinks(w, req, next, prev)
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+func TestLikeAndRechirpThroughRechirp(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+
+	author := createTestUser(t, cfg)
+	sharer := createTestUser(t, cfg)
+	fan := createTestUser(t, cfg)
+
+	original, err := cfg.db.CreateChirp(ctx, database.CreateChirpParams{Body: "original", UserID: author.ID})
+	if err != nil {
+		t.Fatalf("couldn't create a chirp: %v", err)
+	}
+	rechirp, err := cfg.db.CreateRechirp(ctx, database.CreateRechirpParams{
+		UserID:      sharer.ID,
+		RechirpOfID: uuid.NullUUID{UUID: original.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("couldn't create a rechirp: %v", err)
+	}
+
+	call := func(method string, handler http.HandlerFunc) {
+		t.Helper()
+		req := httptest.NewRequest(method, "/api/chirps/"+rechirp.ID.String(), nil)
+		req.SetPathValue("chirpID", rechirp.ID.String())
+		loginAs(t, cfg, req, fan)
+		w := httptest.NewRecorder()
+		cfg.requireAuth(auth.ScopeChirpsWrite, handler)(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s returned %d: %s", method, w.Code, w.Body)
+		}
+	}
+	counts := func() (int32, int32) {
+		t.Helper()
+		chirp, err := cfg.db.GetChirp(ctx, original.ID)
+		if err != nil {
+			t.Fatalf("couldn't get the chirp: %v", err)
+		}
+		return chirp.LikeCount, chirp.RechirpCount
+	}
+
+	call(http.MethodPost, cfg.handlerChirpsLike)
+	if likes, _ := counts(); likes != 1 {
+		t.Fatalf("like count = %d after liking through the rechirp, want 1", likes)
+	}
+	call(http.MethodDelete, cfg.handlerChirpsUnlike)
+	if likes, _ := counts(); likes != 0 {
+		t.Fatalf("like count = %d after unliking through the rechirp, want 0", likes)
+	}
+
+	call(http.MethodPost, cfg.handlerChirpsRechirp)
+	if _, rechirps := counts(); rechirps != 1 {
+		t.Fatalf("rechirp count = %d after rechirping the rechirp, want 1", rechirps)
+	}
+	call(http.MethodDelete, cfg.handlerChirpsUnrechirp)
+	if _, rechirps := counts(); rechirps != 0 {
+		t.Fatalf("rechirp count = %d after undoing through the rechirp, want 0", rechirps)
+	}
+}
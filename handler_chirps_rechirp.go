@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+var errChirpNotFound = errors.New("chirp not found")
+
+// resolveChirpTarget loads the chirp a like, rechirp or reply should land on.
+// Rechirps stand in for the chirp they share, and deleted chirps can't be
+// interacted with.
+func resolveChirpTarget(ctx context.Context, q *database.Queries, chirpID uuid.UUID) (database.Chirp, error) {
+	chirp, err := q.GetChirp(ctx, chirpID)
+	if err == nil && chirp.RechirpOfID.Valid {
+		chirp, err = q.GetChirp(ctx, chirp.RechirpOfID.UUID)
+	}
+	return checkChirpTarget(chirp, err)
+}
+
+// lockChirpTarget is resolveChirpTarget for changes that depend on the
+// target staying as it was read, like a reply the target's delete must see.
+// The target is locked until the transaction ends.
+func lockChirpTarget(ctx context.Context, q *database.Queries, chirpID uuid.UUID) (database.Chirp, error) {
+	chirp, err := q.GetChirp(ctx, chirpID)
+	if err == nil && chirp.RechirpOfID.Valid {
+		chirpID = chirp.RechirpOfID.UUID
+	}
+	if err == nil {
+		chirp, err = q.GetChirpForUpdate(ctx, chirpID)
+	}
+	return checkChirpTarget(chirp, err)
+}
+
+func checkChirpTarget(chirp database.Chirp, err error) (database.Chirp, error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Chirp{}, errChirpNotFound
+	}
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if chirp.DeletedAt.Valid {
+		return database.Chirp{}, errChirpNotFound
+	}
+	return chirp, nil
+}
+
+// chirpsResponse converts chirps for the API and attaches the original to
+// every rechirp, so clients can render them without another round trip.
+func (cfg *apiConfig) chirpsResponse(ctx context.Context, chirps []database.Chirp) ([]Chirp, error) {
+	originalIDs := []uuid.UUID{}
+	for _, chirp := range chirps {
+		if chirp.RechirpOfID.Valid {
+			originalIDs = append(originalIDs, chirp.RechirpOfID.UUID)
+		}
+	}
+
+	originals := map[uuid.UUID]Chirp{}
+	if len(originalIDs) > 0 {
+		rows, err := cfg.db.GetChirpsByIDs(ctx, originalIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			originals[row.ID] = databaseChirpToChirp(row)
+		}
+	}
+
+	resp := []Chirp{}
+	for _, chirp := range chirps {
+		c := databaseChirpToChirp(chirp)
+		if original, ok := originals[chirp.RechirpOfID.UUID]; ok && chirp.RechirpOfID.Valid {
+			c.RechirpOf = &original
+		}
+		resp = append(resp, c)
+	}
+	return resp, nil
+}
+
+func (cfg *apiConfig) handlerChirpsRechirp(w http.ResponseWriter, req *http.Request) {
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse the chirpID", err)
+		return
+	}
+
+	userID := callerOf(req).UserID
+
+	err = cfg.checkCanChirp(req.Context(), userID)
+	if errors.Is(err, errEmailNotVerified) {
+		respondWithError(w, http.StatusForbidden, "Verify your email address before chirping", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check your account", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	original, err := resolveChirpTarget(req.Context(), qtx, chirpID)
+	if errors.Is(err, errChirpNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+
+	params := database.CreateRechirpParams{
+		UserID:      userID,
+		RechirpOfID: uuid.NullUUID{UUID: original.ID, Valid: true},
+	}
+	status := http.StatusCreated
+	rechirp, err := qtx.CreateRechirp(req.Context(), params)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Already rechirped: hand back the existing one.
+		status = http.StatusOK
+		rechirp, err = qtx.GetRechirp(req.Context(), database.GetRechirpParams(params))
+	} else if err == nil {
+		err = qtx.IncrementRechirpCount(req.Context(), original.ID)
+		original.RechirpCount++
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+		return
+	}
+
+	resp := databaseChirpToChirp(rechirp)
+	originalResp := databaseChirpToChirp(original)
+	resp.RechirpOf = &originalResp
+	respondWithJSON(w, status, resp)
+}
+
+func (cfg *apiConfig) handlerChirpsUnrechirp(w http.ResponseWriter, req *http.Request) {
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse the chirpID", err)
+		return
+	}
+
+	userID := callerOf(req).UserID
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't undo the rechirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	original, err := resolveChirpTarget(req.Context(), qtx, chirpID)
+	if errors.Is(err, errChirpNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+
+	deleted, err := qtx.DeleteRechirp(req.Context(), database.DeleteRechirpParams{
+		UserID:      userID,
+		RechirpOfID: uuid.NullUUID{UUID: original.ID, Valid: true},
+	})
+	if err == nil && deleted > 0 {
+		err = qtx.DecrementRechirpCount(req.Context(), original.ID)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't undo the rechirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't undo the rechirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
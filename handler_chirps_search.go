@@ -0,0 +1,109 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/search"
+)
+
+func (cfg *apiConfig) handlerChirpsSearch(w http.ResponseWriter, req *http.Request) {
+	type searchResult struct {
+		Chirp
+		Rank      float32 `json:"rank"`
+		Highlight string  `json:"highlight"`
+	}
+
+	query, err := search.ParseQuery(req.URL.Query().Get("q"))
+	if errors.Is(err, search.ErrEmptyQuery) {
+		respondWithError(w, http.StatusBadRequest, "Search query is empty", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid search query", err)
+		return
+	}
+
+	// Results always come back best match first, so there is no order to
+	// pick.
+	if req.URL.Query().Has("sort") {
+		respondWithError(w, http.StatusBadRequest, "Search results can't be sorted", nil)
+		return
+	}
+
+	page, err := parsePageParams(req.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	page.Desc = true
+
+	authorID := uuid.NullUUID{}
+	authorIDString := req.URL.Query().Get("author_id")
+	if authorIDString != "" {
+		authorID.UUID, err = uuid.Parse(authorIDString)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
+			return
+		}
+		authorID.Valid = true
+	}
+
+	cursorRank := sql.NullFloat64{}
+	if page.Cursor != nil {
+		cursorRank = sql.NullFloat64{Float64: float64(page.Cursor.Rank), Valid: true}
+	}
+	cursorCreatedAt, cursorID := page.cursorArgs()
+
+	var rows []database.SearchChirpsRow
+	if page.queryDesc() {
+		rows, err = cfg.db.SearchChirps(req.Context(), database.SearchChirpsParams{
+			Query:           query,
+			AuthorID:        authorID,
+			Since:           page.Since,
+			Until:           page.Until,
+			CursorRank:      cursorRank,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.queryLimit(),
+		})
+	} else {
+		var reversed []database.SearchChirpsReverseRow
+		reversed, err = cfg.db.SearchChirpsReverse(req.Context(), database.SearchChirpsReverseParams{
+			Query:           query,
+			AuthorID:        authorID,
+			Since:           page.Since,
+			Until:           page.Until,
+			CursorRank:      cursorRank,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.queryLimit(),
+		})
+		for _, row := range reversed {
+			rows = append(rows, database.SearchChirpsRow(row))
+		}
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search chirps", err)
+		return
+	}
+
+	rows, next, prev := paginate(rows, page, func(row database.SearchChirpsRow) pageCursor {
+		return pageCursor{CreatedAt: row.Chirp.CreatedAt, ID: row.Chirp.ID, Rank: row.Rank}
+	})
+
+	results := []searchResult{}
+	for _, row := range rows {
+		results = append(results, searchResult{
+			Chirp:     databaseChirpToChirp(row.Chirp),
+			Rank:      row.Rank,
+			Highlight: search.Highlight(row.Headline),
+		})
+	}
+
+	setPageLinks(w, req, next, prev)
+	respondWithJSON(w, http.StatusOK, results)
+}
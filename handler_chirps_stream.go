@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/pubsub"
+)
+
+const streamHeartbeat = 15 * time.Second
+
+// handlerChirpsStream pushes chirp events to the client as Server-Sent
+// Events. A client that falls behind is disconnected and is expected to
+// reconnect with Last-Event-ID, which EventSource does on its own.
+func (cfg *apiConfig) handlerChirpsStream(w http.ResponseWriter, req *http.Request) {
+	// The bus also carries token events, which must never reach
+	// this public feed.
+	key := ""
+	authorIDString := req.URL.Query().Get("author_id")
+	if authorIDString != "" {
+		authorID, err := uuid.Parse(authorIDString)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
+			return
+		}
+		key = authorID.String()
+	}
+	filter := func(event pubsub.Event) bool {
+		if event.Type != eventChirpCreated && event.Type != eventChirpDeleted {
+			return false
+		}
+		return key == "" || event.Key == key
+	}
+
+	lastID := uint64(0)
+	if lastIDString := req.Header.Get("Last-Event-ID"); lastIDString != "" {
+		var err error
+		lastID, err = strconv.ParseUint(lastIDString, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID", err)
+			return
+		}
+	}
+
+	sub, replay, err := cfg.events.Subscribe(lastID, filter)
+	if errors.Is(err, pubsub.ErrHistoryGap) {
+		// Too far behind to resume; start live and tell the client to
+		// refetch whatever it missed.
+		sub, replay, err = cfg.events.Subscribe(0, filter)
+		if err == nil {
+			replay = append(replay, pubsub.Event{Type: "reset", Data: []byte("{}")})
+		}
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't subscribe to events", err)
+		return
+	}
+	defer cfg.events.Unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range replay {
+		writeSSEEvent(w, event)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			writeSSEEvent(w, event)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeSSEEvent(w http.ResponseWriter, event pubsub.Event) {
+	if event.ID > 0 {
+		fmt.Fprintf(w, "id: %d\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+}
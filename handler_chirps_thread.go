@@ -0,0 +1,153 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+const (
+	defaultThreadDepth = 3
+	maxThreadDepth     = 10
+	// maxThreadDescendants caps how many nested replies one page pulls in.
+	// Clients can open a deeper thread on any reply whose reply_count says
+	// there is more below it.
+	maxThreadDescendants = 500
+)
+
+type threadNode struct {
+	Chirp
+	Replies []*threadNode `json:"replies"`
+}
+
+// handlerChirpsThread returns the chain of chirps a chirp replies to, root
+// first, and a page of its direct replies with their own replies nested up
+// to the requested depth.
+func (cfg *apiConfig) handlerChirpsThread(w http.ResponseWriter, req *http.Request) {
+	type returnVals struct {
+		Ancestors []Chirp     `json:"ancestors"`
+		Chirp     *threadNode `json:"chirp"`
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse the chirpID", err)
+		return
+	}
+
+	page, err := parsePageParams(req.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	depth, err := parseThreadDepth(req.URL.Query().Get("depth"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirp(req.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+
+	ancestors, err := cfg.db.GetChirpAncestors(req.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get the thread", err)
+		return
+	}
+
+	parentID := uuid.NullUUID{UUID: chirpID, Valid: true}
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	var replies []database.Chirp
+	if page.queryDesc() {
+		replies, err = cfg.db.ListChirpRepliesReverse(req.Context(), database.ListChirpRepliesReverseParams{
+			ParentID:        parentID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.queryLimit(),
+		})
+	} else {
+		replies, err = cfg.db.ListChirpReplies(req.Context(), database.ListChirpRepliesParams{
+			ParentID:        parentID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.queryLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get the replies", err)
+		return
+	}
+
+	replies, next, prev := paginate(replies, page, func(chirp database.Chirp) pageCursor {
+		return pageCursor{CreatedAt: chirp.CreatedAt, ID: chirp.ID}
+	})
+
+	root := &threadNode{Chirp: databaseChirpToChirp(chirp), Replies: []*threadNode{}}
+	nodes := map[uuid.UUID]*threadNode{}
+	replyIDs := []uuid.UUID{}
+	for _, reply := range replies {
+		node := &threadNode{Chirp: databaseChirpToChirp(reply), Replies: []*threadNode{}}
+		nodes[reply.ID] = node
+		root.Replies = append(root.Replies, node)
+		replyIDs = append(replyIDs, reply.ID)
+	}
+
+	if depth > 1 && len(replyIDs) > 0 {
+		descendants, err := cfg.db.ListChirpDescendants(req.Context(), database.ListChirpDescendantsParams{
+			ParentIds: replyIDs,
+			MaxDepth:  int32(depth - 1),
+			Limit:     maxThreadDescendants,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't get the replies", err)
+			return
+		}
+
+		// Descendants come back level by level, so a parent is always in
+		// the map before its children.
+		for _, descendant := range descendants {
+			parent, ok := nodes[descendant.ParentID.UUID]
+			if !ok {
+				continue
+			}
+			node := &threadNode{Chirp: databaseChirpToChirp(descendant), Replies: []*threadNode{}}
+			nodes[descendant.ID] = node
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+
+	resp := returnVals{
+		Ancestors: []Chirp{},
+		Chirp:     root,
+	}
+	for _, ancestor := range ancestors {
+		resp.Ancestors = append(resp.Ancestors, databaseChirpToChirp(ancestor))
+	}
+
+	setPageLinks(w, req, next, prev)
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func parseThreadDepth(s string) (int, error) {
+	if s == "" {
+		return defaultThreadDepth, nil
+	}
+	depth, err := strconv.Atoi(s)
+	if err != nil || depth < 1 {
+		return 0, fmt.Errorf("invalid depth %q", s)
+	}
+	return min(depth, maxThreadDepth), nil
+}
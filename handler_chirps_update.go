@@ -0,0 +1,155 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/entitlements"
+)
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+func (cfg *apiConfig) handlerChirpsUpdate(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse the chirpID", err)
+		return
+	}
+
+	userID := callerOf(req).UserID
+
+	plan, err := cfg.planFor(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get your plan", err)
+		return
+	}
+	if !plan.Allows(entitlements.FeatureEditChirps) {
+		respondWithError(w, http.StatusForbidden, "Your plan doesn't include editing chirps", nil)
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	cleaned, err := prepareChirpBody(params.Body, plan.MaxChirpLength)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Lock the row so two concurrent edits can't both archive the same body.
+	chirp, err := qtx.GetChirpForUpdate(req.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) || chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+
+	if userID != chirp.UserID {
+		respondWithError(w, http.StatusForbidden, "You can not edit other's chirps", nil)
+		return
+	}
+	if chirp.RechirpOfID.Valid {
+		respondWithError(w, http.StatusBadRequest, "Rechirps can't be edited", nil)
+		return
+	}
+
+	if cleaned == chirp.Body {
+		respondWithJSON(w, http.StatusOK, databaseChirpToChirp(chirp))
+		return
+	}
+
+	err = qtx.CreateChirpRevision(req.Context(), database.CreateChirpRevisionParams{
+		ChirpID:   chirp.ID,
+		Body:      chirp.Body,
+		CreatedAt: chirp.UpdatedAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save the previous revision", err)
+		return
+	}
+
+	updated, err := qtx.UpdateChirpBody(req.Context(), database.UpdateChirpBodyParams{
+		ID:   chirp.ID,
+		Body: cleaned,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, databaseChirpToChirp(updated))
+}
+
+// handlerChirpsRevisions lists the earlier bodies of a chirp, newest first.
+func (cfg *apiConfig) handlerChirpsRevisions(w http.ResponseWriter, req *http.Request) {
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse the chirpID", err)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirp(req.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) || chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+
+	revisions, err := cfg.db.ListChirpRevisions(req.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get revisions", err)
+		return
+	}
+
+	resp := []ChirpRevision{}
+	for _, revision := range revisions {
+		resp = append(resp, ChirpRevision{
+			ID:         revision.ID,
+			Body:       revision.Body,
+			CreatedAt:  revision.CreatedAt,
+			ReplacedAt: revision.ReplacedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+type Follow struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+func (cfg *apiConfig) handlerFollow(w http.ResponseWriter, req *http.Request) {
+	followeeID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse the userID", err)
+		return
+	}
+
+	userID := callerOf(req).UserID
+
+	if userID == followeeID {
+		respondWithError(w, http.StatusBadRequest, "You can not follow yourself", nil)
+		return
+	}
+
+	_, err = cfg.db.GetUser(req.Context(), followeeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	err = cfg.db.FollowUser(req.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't follow user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollow(w http.ResponseWriter, req *http.Request) {
+	followeeID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse the userID", err)
+		return
+	}
+
+	userID := callerOf(req).UserID
+
+	err = cfg.db.UnfollowUser(req.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unfollow user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerFollowersList(w http.ResponseWriter, req *http.Request) {
+	cfg.listFollows(w, req, true)
+}
+
+func (cfg *apiConfig) handlerFollowingList(w http.ResponseWriter, req *http.Request) {
+	cfg.listFollows(w, req, false)
+}
+
+// listFollows serves both directions of the follow graph, newest first
+// unless sort=asc asks for the oldest first.
+func (cfg *apiConfig) listFollows(w http.ResponseWriter, req *http.Request, followers bool) {
+	type returnVals struct {
+		Count int64    `json:"count"`
+		Users []Follow `json:"users"`
+	}
+
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse the userID", err)
+		return
+	}
+
+	page, err := parsePageParams(req.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if req.URL.Query().Get("sort") == "" {
+		page.Desc = true
+	}
+
+	_, err = cfg.db.GetUser(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	counts, err := cfg.db.GetFollowCounts(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count follows", err)
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	params := database.ListFollowersParams{
+		UserID:          userID,
+		Since:           page.Since,
+		Until:           page.Until,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           page.queryLimit(),
+	}
+
+	var rows []database.ListFollowersRow
+	switch {
+	case followers && page.queryDesc():
+		rows, err = cfg.db.ListFollowers(req.Context(), params)
+	case followers:
+		var reversed []database.ListFollowersReverseRow
+		reversed, err = cfg.db.ListFollowersReverse(req.Context(), database.ListFollowersReverseParams(params))
+		for _, row := range reversed {
+			rows = append(rows, database.ListFollowersRow(row))
+		}
+	case page.queryDesc():
+		var following []database.ListFollowingRow
+		following, err = cfg.db.ListFollowing(req.Context(), database.ListFollowingParams(params))
+		for _, row := range following {
+			rows = append(rows, database.ListFollowersRow(row))
+		}
+	default:
+		var reversed []database.ListFollowingReverseRow
+		reversed, err = cfg.db.ListFollowingReverse(req.Context(), database.ListFollowingReverseParams(params))
+		for _, row := range reversed {
+			rows = append(rows, database.ListFollowersRow(row))
+		}
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list follows", err)
+		return
+	}
+
+	rows, next, prev := paginate(rows, page, func(row database.ListFollowersRow) pageCursor {
+		return pageCursor{CreatedAt: row.FollowedAt, ID: row.UserID}
+	})
+
+	resp := returnVals{
+		Count: counts.Following,
+		Users: []Follow{},
+	}
+	if followers {
+		resp.Count = counts.Followers
+	}
+	for _, row := range rows {
+		resp.Users = append(resp.Users, Follow{
+			UserID:     row.UserID,
+			FollowedAt: row.FollowedAt,
+		})
+	}
+
+	setPageLinks(w, req, next, prev)
+	respondWithJSON(w, http.StatusOK, resp)
+}
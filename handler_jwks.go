@@ -0,0 +1,29 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+)
+
+func (cfg *apiConfig) handlerJWKS(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, cfg.keyring.JWKS())
+}
+
+// loadKeyring reads the signing keys from dir. Without a directory an
+// ephemeral key is generated, which is fine for local development but logs
+// everyone out on restart and can't be shared between instances.
+func loadKeyring(dir, activeID string) (*auth.Keyring, error) {
+	if dir != "" {
+		return auth.LoadKeyring(dir, activeID)
+	}
+
+	log.Println("JWT_KEYS_DIR is not set, signing tokens with an ephemeral key")
+	key, err := auth.GenerateSigningKey("ephemeral")
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewKeyring(key), nil
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/rangaroo/chirpy-http-server/internal/auth"
 	"github.com/rangaroo/chirpy-http-server/internal/database"
 )
@@ -15,12 +19,6 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, req *http.Request) {
 		Email            string `json:"email"`
 	}
 
-	type returnVals struct {
-		User
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
-	}
-
 	decoder := json.NewDecoder(req.Body)
 	params := parameters{}
 	err := decoder.Decode(&params)
@@ -29,43 +27,117 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	throttleKeys := loginThrottleKeys(params.Email, cfg.clientIP(req))
+	lockedUntil, err := cfg.loginLockedUntil(req.Context(), throttleKeys)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+	if !lockedUntil.IsZero() {
+		setRetryAfter(w, lockedUntil)
+		respondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later", nil)
+		return
+	}
+
 	user, err := cfg.db.GetUserByEmail(req.Context(), params.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+
+	match := false
+	if err == nil {
+		match, err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+			return
+		}
+	}
+	if !match {
+		lockedUntil, err := cfg.recordLoginFailure(req.Context(), throttleKeys)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+			return
+		}
+		setRetryAfter(w, lockedUntil)
+		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", nil)
+		return
+	}
+
+	enabled, err := cfg.hasTwoFactor(req.Context(), user.ID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+	if enabled {
+		challenge, err := cfg.startLoginChallenge(req.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, challenge)
 		return
 	}
 
-	match, err := auth.CheckPasswordHash(params.Password, user.HashedPassword)
-	if err != nil || !match {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+	// With two factors the counter is kept until the code is right too, so
+	// a known password can't be used to reset it between guesses.
+	err = cfg.db.ClearLoginThrottle(req.Context(), accountThrottleKey(params.Email))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
 		return
 	}
 
-	tokenString, err := auth.MakeJWT(user.ID, cfg.tokenSecret, time.Hour)
+	session, err := cfg.startSession(req.Context(), user, cfg.deviceOf(req))
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could't create a token string", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
 		return
 	}
 
+	respondWithJSON(w, http.StatusOK, session)
+}
+
+// accessTokenTTL is how long an access token lasts, and so how long a
+// revocation has to be remembered.
+const accessTokenTTL = time.Hour
+
+type loginSession struct {
+	User
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// makeAccessToken issues an access token carrying the user's current roles.
+func (cfg *apiConfig) makeAccessToken(ctx context.Context, user database.User) (string, error) {
+	roles, err := cfg.db.ListUserRoles(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	return cfg.keyring.MakeJWT(user.ID, user.TokenVersion, roles, accessTokenTTL)
+}
+
+// startSession issues the access and refresh tokens of a new login from
+// the given device.
+func (cfg *apiConfig) startSession(ctx context.Context, user database.User, dev device) (loginSession, error) {
+	tokenString, err := cfg.makeAccessToken(ctx, user)
+	if err != nil {
+		return loginSession{}, err
+	}
+
 	refreshTokenString, _ := auth.MakeRefreshToken()
-	refreshToken, err := cfg.db.CreateRefreshToken(req.Context(), database.CreateRefreshTokenParams{
+	refreshToken, err := cfg.db.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
 		Token:     refreshTokenString,
 		UserID:    user.ID,
+		FamilyID:  uuid.New(),
+		UserAgent: dev.userAgent,
+		IpAddress: dev.ip,
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could't create a refresh token", err)
-		return
+		return loginSession{}, err
 	}
 
-	respondWithJSON(w, http.StatusOK, returnVals{
-		User: User{
-			ID:        user.ID,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-			Email:     user.Email,
-			IsChirpyRed: user.IsChirpyRed,
-		},
+	return loginSession{
+		User:         databaseUserToUser(user),
 		Token:        tokenString,
 		RefreshToken: refreshToken.Token,
-	})
+	}, nil
 }
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/mailer"
+)
+
+const passwordResetTTL = time.Hour
+
+// handlerPasswordResetRequest mails a reset link if the address belongs to
+// an account. It answers the same way either way so it can't be used to
+// find out who has signed up.
+func (cfg *apiConfig) handlerPasswordResetRequest(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserByEmail(req.Context(), params.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start the reset", err)
+		return
+	}
+
+	token, err := auth.MakeToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start the reset", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start the reset", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.CreatePasswordResetToken(req.Context(), database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(passwordResetTTL),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start the reset", err)
+		return
+	}
+
+	link := cfg.publicURL + "/app/reset-password?token=" + url.QueryEscape(token)
+	err = enqueueEmail(req.Context(), qtx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Chirpy account.\n\n"+
+			"To choose a new one, open this link within the next hour:\n\n%s\n\n"+
+			"If it wasn't you, you can ignore this email.\n", link),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start the reset", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start the reset", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+var errInvalidResetToken = errors.New("invalid or expired reset token")
+
+// handlerPasswordResetConfirm sets a new password with a token from a reset
+// email.
+func (cfg *apiConfig) handlerPasswordResetConfirm(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "Password can't be empty", nil)
+		return
+	}
+
+	err = cfg.resetPassword(req.Context(), params.Token, params.Password)
+	if errors.Is(err, errInvalidResetToken) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired reset token", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset the password", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resetPassword sets a new password with a token from a reset email. Every
+// outstanding reset token of the user is spent and every session, access
+// token, personal access token and app authorization is revoked, so
+// whoever knew the old password loses access.
+func (cfg *apiConfig) resetPassword(ctx context.Context, token, password string) error {
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	resetToken, err := qtx.GetPasswordResetTokenForUpdate(ctx, auth.HashToken(token))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if errors.Is(err, sql.ErrNoRows) || resetToken.UsedAt.Valid || time.Now().After(resetToken.ExpiresAt) {
+		return errInvalidResetToken
+	}
+
+	err = qtx.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:             resetToken.UserID,
+		HashedPassword: hashed,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = qtx.UsePasswordResetTokens(ctx, resetToken.UserID)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.RevokeUserRefreshTokens(ctx, resetToken.UserID)
+	if err != nil {
+		return err
+	}
+
+	revocation, err := revokeUserTokens(ctx, qtx, resetToken.UserID)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.RevokeUserPersonalAccessTokens(ctx, resetToken.UserID)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.RevokeUserOAuthRefreshTokens(ctx, resetToken.UserID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	cfg.announceRevocation(revocation)
+	return nil
+}
+
+type passwordResetPage struct {
+	Token string
+	Error string
+	Done  bool
+}
+
+var passwordResetTemplate = template.Must(template.New("password-reset").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Reset your password - Chirpy</title>
+  <style>
+    body { font-family: sans-serif; max-width: 28rem; margin: 3rem auto; padding: 0 1rem; }
+    label { display: block; margin-top: 0.75rem; }
+    input { width: 100%; padding: 0.4rem; box-sizing: border-box; }
+    .error { color: #b00020; }
+    .buttons { margin-top: 1.5rem; }
+  </style>
+</head>
+<body>
+  <h1>Reset your password</h1>
+{{if .Done}}
+  <p>Your password has been changed and you have been logged out everywhere. Log in again with the new one.</p>
+{{else if .Token}}
+  {{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+  <form method="post" action="/app/reset-password">
+    <input type="hidden" name="token" value="{{.Token}}">
+    <label>New password <input type="password" name="password" autocomplete="new-password" required autofocus></label>
+    <div class="buttons"><button type="submit">Set password</button></div>
+  </form>
+{{else}}
+  <p class="error">{{.Error}}</p>
+{{end}}
+</body>
+</html>
+`))
+
+// handlerPasswordResetPage is where the link in a reset email leads. The
+// token stays in a hidden field until the new password is submitted.
+func (cfg *apiConfig) handlerPasswordResetPage(w http.ResponseWriter, req *http.Request) {
+	page := passwordResetPage{Token: req.URL.Query().Get("token")}
+	if page.Token == "" {
+		page.Error = "This reset link is incomplete. Copy the whole link from the email."
+		renderPage(w, http.StatusBadRequest, passwordResetTemplate, page)
+		return
+	}
+	renderPage(w, http.StatusOK, passwordResetTemplate, page)
+}
+
+// handlerPasswordResetForm takes the form of the reset page.
+func (cfg *apiConfig) handlerPasswordResetForm(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		renderPage(w, http.StatusBadRequest, passwordResetTemplate, passwordResetPage{Error: "Couldn't read the form."})
+		return
+	}
+	page := passwordResetPage{Token: req.PostForm.Get("token")}
+
+	password := req.PostForm.Get("password")
+	if password == "" {
+		page.Error = "Password can't be empty."
+		renderPage(w, http.StatusBadRequest, passwordResetTemplate, page)
+		return
+	}
+
+	err = cfg.resetPassword(req.Context(), page.Token, password)
+	if errors.Is(err, errInvalidResetToken) {
+		page = passwordResetPage{Error: "This reset link is invalid or has expired. Ask for a new one."}
+		renderPage(w, http.StatusBadRequest, passwordResetTemplate, page)
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't reset the password: %s", err)
+		page.Error = "Something went wrong, try again later."
+		renderPage(w, http.StatusInternalServerError, passwordResetTemplate, page)
+		return
+	}
+
+	renderPage(w, http.StatusOK, passwordResetTemplate, passwordResetPage{Done: true})
+}
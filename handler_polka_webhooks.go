@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/polka"
+)
+
+const (
+	// polkaReplayWindow is how far a signed timestamp may drift from our
+	// clock. Anything older is rejected even with a valid signature.
+	polkaReplayWindow = 5 * time.Minute
+	maxWebhookBody    = 1 << 20
+)
+
+var (
+	errWebhookProcessed   = errors.New("webhook event has already been processed")
+	errPolkaUserNotFound  = errors.New("webhook refers to an unknown user")
+	errPolkaEventMismatch = errors.New("webhook payload doesn't match the stored event")
+)
+
+// handlerPolkaWebhooks records every signed event Polka sends and applies
+// it exactly once. Polka retries anything that isn't a 2xx, so duplicates
+// of an event that was already applied are acknowledged without effect.
+func (cfg *apiConfig) handlerPolkaWebhooks(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxWebhookBody))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read the request body", err)
+		return
+	}
+
+	err = auth.VerifyWebhookSignature(req.Header, cfg.apiKey, body, polkaReplayWindow, time.Now())
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid webhook signature", err)
+		return
+	}
+
+	event := polka.Event{}
+	err = json.Unmarshal(body, &event)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode the event", err)
+		return
+	}
+	if event.ID == "" {
+		respondWithError(w, http.StatusBadRequest, "Event is missing an id", nil)
+		return
+	}
+
+	err = cfg.db.RecordWebhookEvent(req.Context(), database.RecordWebhookEventParams{
+		EventID:   event.ID,
+		EventType: event.Event,
+		Payload:   body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record the event", err)
+		return
+	}
+
+	err = cfg.processWebhookEvent(req.Context(), event.ID)
+	if errors.Is(err, errWebhookProcessed) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if errors.Is(err, errPolkaUserNotFound) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find the user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't process the event", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// processWebhookEvent applies a stored event unless it has been applied
+// before. The row stays locked until the effects are committed, so
+// concurrent deliveries of the same event can't both go through. A failure
+// is written back to the event for the admin listing.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, eventID string) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	stored, err := qtx.GetWebhookEventByEventIDForUpdate(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if stored.ProcessedAt.Valid {
+		return errWebhookProcessed
+	}
+
+	user, err := applyPolkaEvent(ctx, qtx, stored)
+	if err == nil {
+		err = qtx.MarkWebhookEventProcessed(ctx, stored.ID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		tx.Rollback()
+		markErr := cfg.db.MarkWebhookEventFailed(ctx, database.MarkWebhookEventFailedParams{
+			ID:        stored.ID,
+			LastError: sql.NullString{String: err.Error(), Valid: true},
+		})
+		if markErr != nil {
+			return errors.Join(err, markErr)
+		}
+		return err
+	}
+
+	if user != nil {
+		cfg.publish(eventUserUpdated, user.ID, databaseUserToUser(*user))
+	}
+	return nil
+}
+
+// applyPolkaEvent makes the changes an event asks for and returns the user
+// it touched, if any.
+func applyPolkaEvent(ctx context.Context, q *database.Queries, stored database.WebhookEvent) (*database.User, error) {
+	event := polka.Event{}
+	err := json.Unmarshal(stored.Payload, &event)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode the stored payload: %w", err)
+	}
+	if event.ID != stored.EventID {
+		return nil, errPolkaEventMismatch
+	}
+
+	return applySubscriptionEvent(ctx, q, stored.ID, event, time.Now().UTC())
+}
@@ -1,40 +1,125 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+var (
+	errRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	errRefreshTokenReused  = errors.New("refresh token was already used")
 )
 
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, req *http.Request) {
 	type returnVals struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
 	refreshTokenString, err := auth.GetBearerToken(req.Header)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Could't parse the token", err)
+		respondWithError(w, http.StatusBadRequest, "Couldn't parse the token", err)
 		return
 	}
 
-	user, err := cfg.db.GetUserFromRefreshToken(req.Context(), refreshTokenString)
+	refreshToken, err := cfg.rotateRefreshToken(req.Context(), refreshTokenString, cfg.deviceOf(req))
+	if errors.Is(err, errRefreshTokenInvalid) || errors.Is(err, errRefreshTokenReused) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token", err)
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Could't get the user for refresh token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rotate the refresh token", err)
 		return
 	}
 
-	tokenString, err := auth.MakeJWT(user.ID, cfg.tokenSecret, time.Hour)
+	user, err := cfg.db.GetUser(req.Context(), refreshToken.UserID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	tokenString, err := cfg.makeAccessToken(req.Context(), user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create a token string", err)
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, returnVals{
-		Token: tokenString,
+		Token:        tokenString,
+		RefreshToken: refreshToken.Token,
 	})
 }
 
+// rotateRefreshToken revokes the presented refresh token and issues its
+// successor in the same family, noting the device that refreshed. Presenting
+// a token that was already revoked means it leaked, so the whole family is
+// revoked with it.
+func (cfg *apiConfig) rotateRefreshToken(ctx context.Context, tokenString string, dev device) (database.RefreshToken, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.RefreshToken{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	old, err := qtx.GetRefreshTokenForUpdate(ctx, tokenString)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.RefreshToken{}, errRefreshTokenInvalid
+	}
+	if err != nil {
+		return database.RefreshToken{}, err
+	}
+
+	if old.RevokedAt.Valid {
+		err = qtx.RevokeRefreshTokenFamily(ctx, old.FamilyID)
+		if err != nil {
+			return database.RefreshToken{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return database.RefreshToken{}, err
+		}
+		return database.RefreshToken{}, errRefreshTokenReused
+	}
+
+	if time.Now().After(old.ExpiresAt) {
+		return database.RefreshToken{}, errRefreshTokenInvalid
+	}
+
+	_, err = qtx.RevokeRefreshToken(ctx, old.Token)
+	if err != nil {
+		return database.RefreshToken{}, err
+	}
+
+	newTokenString, err := auth.MakeRefreshToken()
+	if err != nil {
+		return database.RefreshToken{}, err
+	}
+
+	refreshToken, err := qtx.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     newTokenString,
+		UserID:    old.UserID,
+		FamilyID:  old.FamilyID,
+		UserAgent: dev.userAgent,
+		IpAddress: dev.ip,
+	})
+	if err != nil {
+		return database.RefreshToken{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return database.RefreshToken{}, err
+	}
+	return refreshToken, nil
+}
+
 func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, req *http.Request) {
 	refreshTokenString, err := auth.GetBearerToken(req.Header)
 	if err != nil {
@@ -42,7 +127,11 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	err = cfg.db.RevokeRefreshToken(req.Context(), refreshTokenString)
+	_, err = cfg.db.RevokeRefreshToken(req.Context(), refreshTokenString)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could't revoke the token", err)
 		return
@@ -50,3 +139,48 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, req *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// handlerLogout ends the session of the access token it is called with:
+// the token itself is refused from then on, and so is the refresh token if
+// one is passed along.
+func (cfg *apiConfig) handlerLogout(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	token := callerOf(req).Token
+
+	params := parameters{}
+	if req.ContentLength != 0 {
+		err := json.NewDecoder(req.Body).Decode(&params)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+			return
+		}
+	}
+
+	if token.ID != uuid.Nil {
+		revocation, err := cfg.db.RevokeAccessToken(req.Context(), database.RevokeAccessTokenParams{
+			UserID:    token.UserID,
+			TokenID:   uuid.NullUUID{UUID: token.ID, Valid: true},
+			ExpiresAt: token.ExpiresAt,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the token", err)
+			return
+		}
+		cfg.announceRevocation(revocation)
+	}
+
+	// Holding the refresh token is enough to revoke it, as with
+	// POST /api/revoke.
+	if params.RefreshToken != "" {
+		_, err := cfg.db.RevokeRefreshToken(req.Context(), params.RefreshToken)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the refresh token", err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
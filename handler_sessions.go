@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+// maxUserAgentLength keeps a client from filling the table with a huge
+// header.
+const maxUserAgentLength = 512
+
+// device is what a session remembers about the client that holds it.
+type device struct {
+	userAgent string
+	ip        string
+}
+
+func (cfg *apiConfig) deviceOf(req *http.Request) device {
+	userAgent := req.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	return device{
+		userAgent: userAgent,
+		ip:        cfg.clientIP(req),
+	}
+}
+
+// Session is one login: a refresh token family, followed through its
+// rotations. Its ID is the family ID.
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	SignedInAt time.Time `json:"signed_in_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// handlerSessionsList shows where the user is logged in, most recently
+// refreshed first. Details are those of the latest refresh, since that is
+// when a client last showed itself.
+func (cfg *apiConfig) handlerSessionsList(w http.ResponseWriter, req *http.Request) {
+	userID := callerOf(req).UserID
+
+	rows, err := cfg.db.ListUserSessions(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list sessions", err)
+		return
+	}
+
+	sessions := make([]Session, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, Session{
+			ID:         row.FamilyID,
+			UserAgent:  row.UserAgent,
+			IPAddress:  row.IpAddress,
+			SignedInAt: row.SignedInAt,
+			LastUsedAt: row.LastUsedAt,
+			ExpiresAt:  row.ExpiresAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, sessions)
+}
+
+// handlerSessionsRevoke logs one device out, the same way POST /api/revoke
+// does with the refresh token in hand. Access tokens the device already
+// holds keep working until they expire.
+func (cfg *apiConfig) handlerSessionsRevoke(w http.ResponseWriter, req *http.Request) {
+	userID := callerOf(req).UserID
+
+	sessionID, err := uuid.Parse(req.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid session ID", err)
+		return
+	}
+
+	revoked, err := cfg.db.RevokeUserSession(req.Context(), database.RevokeUserSessionParams{
+		UserID:   userID,
+		FamilyID: sessionID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the session", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find session", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerSessionsRevokeAll logs the user out everywhere, including the
+// device making the request and apps they authorized. Access tokens already
+// handed out stop working too.
+func (cfg *apiConfig) handlerSessionsRevokeAll(w http.ResponseWriter, req *http.Request) {
+	userID := callerOf(req).UserID
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the sessions", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.RevokeUserRefreshTokens(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the sessions", err)
+		return
+	}
+
+	revocation, err := revokeUserTokens(req.Context(), qtx, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the sessions", err)
+		return
+	}
+
+	err = qtx.RevokeUserOAuthRefreshTokens(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the sessions", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the sessions", err)
+		return
+	}
+
+	cfg.announceRevocation(revocation)
+
+	w.WriteHeader(http.StatusNoContent)
+}
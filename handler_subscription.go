@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type SubscriptionEvent struct {
+	EventType string    `json:"event_type"`
+	Status    string    `json:"status"`
+	PeriodEnd time.Time `json:"period_end"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// handlerSubscriptionGet returns the caller's Chirpy Red subscription and
+// every transition it went through, newest first.
+func (cfg *apiConfig) handlerSubscriptionGet(w http.ResponseWriter, req *http.Request) {
+	type returnVals struct {
+		Plan             string              `json:"plan"`
+		Status           string              `json:"status"`
+		CurrentPeriodEnd time.Time           `json:"current_period_end"`
+		GracePeriodEnd   *time.Time          `json:"grace_period_end"`
+		History          []SubscriptionEvent `json:"history"`
+	}
+
+	userID := callerOf(req).UserID
+
+	sub, err := cfg.db.GetSubscriptionByUser(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "No subscription", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get the subscription", err)
+		return
+	}
+
+	history, err := cfg.db.ListSubscriptionEvents(req.Context(), sub.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get the subscription history", err)
+		return
+	}
+
+	resp := returnVals{
+		Plan:             sub.Plan,
+		Status:           sub.Status,
+		CurrentPeriodEnd: sub.CurrentPeriodEnd,
+		History:          []SubscriptionEvent{},
+	}
+	if sub.GracePeriodEnd.Valid {
+		resp.GracePeriodEnd = &sub.GracePeriodEnd.Time
+	}
+	for _, event := range history {
+		resp.History = append(resp.History, SubscriptionEvent{
+			EventType: event.EventType,
+			Status:    event.Status,
+			PeriodEnd: event.PeriodEnd,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+// handlerTimeline returns chirps by the caller and everyone they follow,
+// newest first unless sort=asc asks for the oldest first.
+func (cfg *apiConfig) handlerTimeline(w http.ResponseWriter, req *http.Request) {
+	userID := callerOf(req).UserID
+
+	page, err := parsePageParams(req.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if req.URL.Query().Get("sort") == "" {
+		page.Desc = true
+	}
+
+	cursorCreatedAt, cursorID := page.cursorArgs()
+	var chirps []database.Chirp
+	if page.queryDesc() {
+		chirps, err = cfg.db.ListTimeline(req.Context(), database.ListTimelineParams{
+			UserID:          userID,
+			Since:           page.Since,
+			Until:           page.Until,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.queryLimit(),
+		})
+	} else {
+		chirps, err = cfg.db.ListTimelineReverse(req.Context(), database.ListTimelineReverseParams{
+			UserID:          userID,
+			Since:           page.Since,
+			Until:           page.Until,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.queryLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get timeline", err)
+		return
+	}
+
+	chirps, next, prev := paginate(chirps, page, func(chirp database.Chirp) pageCursor {
+		return pageCursor{CreatedAt: chirp.CreatedAt, ID: chirp.ID}
+	})
+
+	responseChirps, err := cfg.chirpsResponse(req.Context(), chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get timeline", err)
+		return
+	}
+
+	setPageLinks(w, req, next, prev)
+	respondWithJSON(w, http.StatusOK, responseChirps)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+const maxTokenNameLength = 100
+
+// PersonalAccessToken never includes the token itself, which is only shown
+// when it is created.
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func databaseTokenToToken(token database.PersonalAccessToken) PersonalAccessToken {
+	result := PersonalAccessToken{
+		ID:        token.ID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt,
+	}
+	if token.ExpiresAt.Valid {
+		result.ExpiresAt = &token.ExpiresAt.Time
+	}
+	if token.LastUsedAt.Valid {
+		result.LastUsedAt = &token.LastUsedAt.Time
+	}
+	return result
+}
+
+// handlerTokensCreate issues a personal access token. Only a login can do
+// that, so one token can't be used to mint broader ones.
+func (cfg *apiConfig) handlerTokensCreate(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	type returnVals struct {
+		PersonalAccessToken
+		Token string `json:"token"`
+	}
+
+	userID := callerOf(req).UserID
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	if params.Name == "" || len(params.Name) > maxTokenNameLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Name must be 1 to %d characters", maxTokenNameLength), nil)
+		return
+	}
+	if len(params.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one scope is required", nil)
+		return
+	}
+	for _, scope := range params.Scopes {
+		if !auth.ValidScope(scope) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown scope %q", scope), nil)
+			return
+		}
+	}
+	expiresAt := sql.NullTime{}
+	if params.ExpiresAt != nil {
+		if !params.ExpiresAt.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "expires_at must be in the future", nil)
+			return
+		}
+		expiresAt = sql.NullTime{Time: params.ExpiresAt.UTC(), Valid: true}
+	}
+
+	tokenString, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create the token", err)
+		return
+	}
+
+	token, err := cfg.db.CreatePersonalAccessToken(req.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    userID,
+		Name:      params.Name,
+		TokenHash: auth.HashToken(tokenString),
+		Scopes:    params.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create the token", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, returnVals{
+		PersonalAccessToken: databaseTokenToToken(token),
+		Token:               tokenString,
+	})
+}
+
+func (cfg *apiConfig) handlerTokensList(w http.ResponseWriter, req *http.Request) {
+	userID := callerOf(req).UserID
+
+	tokens, err := cfg.db.ListPersonalAccessTokens(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list tokens", err)
+		return
+	}
+
+	result := make([]PersonalAccessToken, 0, len(tokens))
+	for _, token := range tokens {
+		result = append(result, databaseTokenToToken(token))
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// handlerTokensRevoke takes effect on the next request made with the token,
+// since personal access tokens are looked up every time.
+func (cfg *apiConfig) handlerTokensRevoke(w http.ResponseWriter, req *http.Request) {
+	userID := callerOf(req).UserID
+
+	tokenID, err := uuid.Parse(req.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid token ID", err)
+		return
+	}
+
+	revoked, err := cfg.db.RevokePersonalAccessToken(req.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the token", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find token", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -17,6 +17,28 @@ type User struct {
 		Email       string    `json:"email"`
 		Password    string    `json:"-"`
 		IsChirpyRed bool      `json:"is_chirpy_red"`
+		// ChirpyRedUntil is when the membership ends or ended, including any
+		// grace period after a failed payment.
+		ChirpyRedUntil *time.Time `json:"chirpy_red_until"`
+		EmailVerified  bool       `json:"email_verified"`
+		// PendingEmail is a new address waiting to be confirmed.
+		PendingEmail string `json:"pending_email,omitempty"`
+}
+
+func databaseUserToUser(user database.User) User {
+	resp := User{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		PendingEmail:  user.PendingEmail.String,
+	}
+	if user.ChirpyRedUntil.Valid {
+		resp.ChirpyRedUntil = &user.ChirpyRedUntil.Time
+	}
+	return resp
 }
 
 func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, req *http.Request) {
@@ -42,22 +64,43 @@ func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, req *http.Reques
 		respondWithError(w, http.StatusInternalServerError, "Could't hash password", err)
 	}
 
-	user, err := cfg.db.CreateUser(req.Context(), database.CreateUserParams{
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could't create user", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	user, err := qtx.CreateUser(req.Context(), database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: hashed,
 	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email is already taken", err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could't create user", err)
 		return
 	}
 
+	// New accounts start unverified until the link in this email is opened.
+	err = cfg.sendVerificationEmail(req.Context(), qtx, user.ID, user.Email)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could't send the verification email", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could't create user", err)
+		return
+	}
+
+	cfg.publish(eventUserCreated, user.ID, databaseUserToUser(user))
+
 	respondWithJSON(w, http.StatusCreated, returnVals{
-		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
-		},
+		User: databaseUserToUser(user),
 	})
 }
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/rangaroo/chirpy-http-server/internal/auth"
 	"github.com/rangaroo/chirpy-http-server/internal/database"
 )
 
+// handlerUsersUpdate changes the password or the email. Both lead to taking
+// the account over, the email through a password reset, so it needs a login
+// session rather than a token.
 func (cfg *apiConfig) handlerUsersUpdate(w http.ResponseWriter, req *http.Request) {
 	type parameters struct {
 		Password string `json:"password"`
@@ -16,36 +21,66 @@ func (cfg *apiConfig) handlerUsersUpdate(w http.ResponseWriter, req *http.Reques
 
 	type returnVals struct {
 		User
+		// A password change signs out every session, so the caller gets a
+		// new one.
+		Token        string `json:"token,omitempty"`
+		RefreshToken string `json:"refresh_token,omitempty"`
 	}
 
-	accessToken, err := auth.GetBearerToken(req.Header)
+	UserID := callerOf(req).UserID
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Could't parse the token", err)
+		respondWithError(w, http.StatusInternalServerError, "Could't decode parameters", err)
 		return
 	}
 
-	UserID, err := auth.ValidateJWT(accessToken, cfg.tokenSecret)
+	hashed, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could't hash password", err)
+	}
+
+	current, err := cfg.db.GetUser(req.Context(), UserID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid token", err)
+		respondWithError(w, http.StatusInternalServerError, "Could't get user", err)
 		return
 	}
 
-	decoder := json.NewDecoder(req.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
+	samePassword, err := auth.CheckPasswordHash(params.Password, current.HashedPassword)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could't decode parameters", err)
+		respondWithError(w, http.StatusInternalServerError, "Could't update user", err)
 		return
 	}
 
-	hashed, err := auth.HashPassword(params.Password)
+	// A new email only replaces the current one once it is confirmed. Left
+	// out, any change already waiting for confirmation is kept.
+	pendingEmail := sql.NullString{}
+	if params.Email != "" && params.Email != current.Email {
+		_, err := cfg.db.GetUserByEmail(req.Context(), params.Email)
+		if err == nil {
+			respondWithError(w, http.StatusConflict, "Email is already taken", nil)
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "Could't update user", err)
+			return
+		}
+		pendingEmail = sql.NullString{String: params.Email, Valid: true}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could't hash password", err)
+		respondWithError(w, http.StatusInternalServerError, "Could't update user", err)
+		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
-	user, err := cfg.db.UpdateUser(req.Context(), database.UpdateUserParams{
-		Email:          params.Email,
+	user, err := qtx.UpdateUser(req.Context(), database.UpdateUserParams{
 		HashedPassword: hashed,
+		PendingEmail:   pendingEmail,
 		ID:             UserID,
 	})
 	if err != nil {
@@ -53,13 +88,67 @@ func (cfg *apiConfig) handlerUsersUpdate(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, returnVals{
-		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
-		},
-	})
+	// Tokens issued under the old password stop working, including refresh
+	// tokens, personal access tokens and app authorizations, or whoever
+	// stole a session could keep it alive through any of them.
+	revocation := database.AccessTokenRevocation{}
+	if !samePassword {
+		revocation, err = revokeUserTokens(req.Context(), qtx, user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could't update user", err)
+			return
+		}
+		err = qtx.RevokeUserRefreshTokens(req.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could't update user", err)
+			return
+		}
+		err = qtx.RevokeUserPersonalAccessTokens(req.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could't update user", err)
+			return
+		}
+		err = qtx.RevokeUserOAuthRefreshTokens(req.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could't update user", err)
+			return
+		}
+	}
+
+	if pendingEmail.Valid {
+		err = cfg.sendVerificationEmail(req.Context(), qtx, user.ID, pendingEmail.String)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could't send the verification email", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could't update user", err)
+		return
+	}
+	cfg.publish(eventUserUpdated, user.ID, databaseUserToUser(user))
+
+	resp := returnVals{
+		User: databaseUserToUser(user),
+	}
+	if !samePassword {
+		cfg.announceRevocation(revocation)
+
+		user, err = cfg.db.GetUser(req.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't start a new session", err)
+			return
+		}
+		session, err := cfg.startSession(req.Context(), user, cfg.deviceOf(req))
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't start a new session", err)
+			return
+		}
+		resp.Token = session.Token
+		resp.RefreshToken = session.RefreshToken
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
 }
@@ -1,55 +0,0 @@
-package main
-
-import (
-	"encoding/json"
-	"net/http"
-
-	"github.com/rangaroo/chirpy-http-server/internal/auth"
-	"github.com/google/uuid"
-)
-
-func (cfg *apiConfig) handlerUsersUpgrade(w http.ResponseWriter, req *http.Request) {
-	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
-	}
-
-	apiKey, err := auth.GetAPIKey(req.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Could't parse API key", err)
-		return
-	}
-	if apiKey != cfg.apiKey {
-		respondWithError(w, http.StatusUnauthorized, "Invalid API key", err)
-		return
-	}
-
-	decoder := json.NewDecoder(req.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could't decode parameters", err)
-		return
-	}
-
-	userID, err := uuid.Parse(params.Data.UserID)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could't parse user id", err)
-		return
-	}
-
-	if params.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-
-	_, err = cfg.db.UpgradeUser(req.Context(), userID)
-	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Could't upgrade user", err)
-		return
-	}
-
-	respondWithJSON(w, http.StatusNoContent, struct{}{})
-}
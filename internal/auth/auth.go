@@ -7,6 +7,7 @@ import (
 	"errors"
 	"time"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -26,6 +27,12 @@ func GetAPIKey(headers http.Header) (string, error) {
 }
 
 func MakeRefreshToken() (string, error) {
+	return MakeToken()
+}
+
+// MakeToken returns a random opaque token for links sent by email and
+// other bearer secrets that aren't JWTs.
+func MakeToken() (string, error) {
 	key := make([]byte, 32)
 	rand.Read(key)
 
@@ -33,8 +40,22 @@ func MakeRefreshToken() (string, error) {
 	return token, nil
 }
 
+// HashToken is what gets stored for single-use tokens. They are random
+// enough that a plain SHA-256 can't be brute-forced.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ErrNoAuthHeader means the request carries no credentials at all, as
+// opposed to ones in the wrong format.
+var ErrNoAuthHeader = errors.New("no Authorization header")
+
 func GetBearerToken(headers http.Header) (string, error) {
 	headerString := headers.Get("Authorization")
+	if strings.TrimSpace(headerString) == "" {
+		return "", ErrNoAuthHeader
+	}
 
 	splitted := strings.Fields(headerString)
 	if len(splitted) != 2  || splitted[0] != "Bearer" {
@@ -3,6 +3,7 @@ package auth
 import (
 	"testing"
 
+	"errors"
 	"net/http"
 	"time"
 	"github.com/google/uuid"
@@ -72,6 +73,20 @@ func TestGetBearerToken(t *testing.T) {
 	}
 }
 
+func TestGetBearerTokenMissingHeader(t *testing.T) {
+	_, err := GetBearerToken(http.Header{})
+	if !errors.Is(err, ErrNoAuthHeader) {
+		t.Fatalf("expected ErrNoAuthHeader, got %v", err)
+	}
+
+	h := http.Header{}
+	h.Set("Authorization", "Basic token")
+	_, err = GetBearerToken(h)
+	if err == nil || errors.Is(err, ErrNoAuthHeader) {
+		t.Fatalf("expected a format error, got %v", err)
+	}
+}
+
 func TestMakeAndValidateJWT(t *testing.T) {
 	userID := uuid.New()
 	secret := "test-secret-123"
@@ -198,3 +213,18 @@ func TestCheckPasswordHash(t *testing.T) {
 	}
 }
 
+
+func TestMakeAndHashToken(t *testing.T) {
+	token1, _ := MakeToken()
+	token2, _ := MakeToken()
+	if token1 == token2 || len(token1) != 64 {
+		t.Fatalf("expected distinct 64-character tokens, got %q and %q", token1, token2)
+	}
+
+	if HashToken(token1) != HashToken(token1) {
+		t.Error("HashToken should be deterministic")
+	}
+	if HashToken(token1) == HashToken(token2) || HashToken(token1) == token1 {
+		t.Error("HashToken should map tokens to distinct digests")
+	}
+}
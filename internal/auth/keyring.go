@@ -0,0 +1,374 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// SigningKey is one asymmetric key in a Keyring, identified by the kid
+// header of the tokens it signs.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+}
+
+func NewSigningKey(id string, private crypto.Signer) (*SigningKey, error) {
+	if id == "" {
+		return nil, errors.New("signing key needs an ID")
+	}
+
+	switch private.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, Private: private}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, Private: private}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", private)
+	}
+}
+
+// GenerateSigningKey creates a fresh Ed25519 key.
+func GenerateSigningKey(id string) (*SigningKey, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(id, private)
+}
+
+// ParseSigningKeyPEM reads a PKCS#8 (or PKCS#1 RSA) private key.
+func ParseSigningKeyPEM(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+		return NewSigningKey(id, signer)
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(id, key)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// Keyring signs tokens with its active key and verifies tokens signed by any
+// key it holds, so a new key can be introduced before the old one is retired.
+type Keyring struct {
+	mu           sync.RWMutex
+	activeID     string
+	keys         map[string]*SigningKey
+	legacySecret []byte
+	revocations  RevocationChecker
+}
+
+func NewKeyring(active *SigningKey, others ...*SigningKey) *Keyring {
+	k := &Keyring{
+		activeID: active.ID,
+		keys:     map[string]*SigningKey{active.ID: active},
+	}
+	for _, key := range others {
+		k.keys[key.ID] = key
+	}
+	return k
+}
+
+// LoadKeyring reads every *.pem file in dir, using the file name without its
+// extension as the key ID. activeID picks the signing key; when it is empty
+// the last ID in lexical order is used, so date-named keys rotate naturally.
+func LoadKeyring(dir, activeID string) (*Keyring, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no *.pem keys found in %s", dir)
+	}
+	sort.Strings(paths)
+
+	keys := []*SigningKey{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		key, err := ParseSigningKeyPEM(id, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	if activeID == "" {
+		activeID = keys[len(keys)-1].ID
+	}
+	for i, key := range keys {
+		if key.ID == activeID {
+			others := append(keys[:i:i], keys[i+1:]...)
+			return NewKeyring(key, others...), nil
+		}
+	}
+	return nil, fmt.Errorf("active key %q not found in %s", activeID, dir)
+}
+
+// SetLegacySecret lets the keyring keep accepting HS256 tokens without a kid,
+// as issued before asymmetric keys were introduced. It never signs with it.
+func (k *Keyring) SetLegacySecret(secret string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.legacySecret = []byte(secret)
+}
+
+// SetRevocationChecker makes validation refuse tokens that c reports as
+// revoked, even though their signature and expiry are fine.
+func (k *Keyring) SetRevocationChecker(c RevocationChecker) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.revocations = c
+}
+
+func (k *Keyring) Add(key *SigningKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[key.ID] = key
+}
+
+// Activate switches signing to the key with the given ID. Tokens signed by
+// the previous key stay valid until it is removed.
+func (k *Keyring) Activate(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("unknown key %q", id)
+	}
+	k.activeID = id
+	return nil
+}
+
+func (k *Keyring) Remove(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if id == k.activeID {
+		return errors.New("can't remove the active key")
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+func (k *Keyring) active() *SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[k.activeID]
+}
+
+// AccessClaims are the claims of an access token. TokenVersion is the
+// user's token version when it was issued; bumping the version revokes
+// every token issued before. Roles are the user's roles at that time.
+// Tokens issued to an OAuth client name it in ClientID and are limited to
+// Scope, as in RFC 9068.
+type AccessClaims struct {
+	jwt.RegisteredClaims
+	TokenVersion int32    `json:"ver"`
+	Roles        []string `json:"roles,omitempty"`
+	ClientID     string   `json:"client_id,omitempty"`
+	Scope        string   `json:"scope,omitempty"`
+}
+
+// AccessToken is a validated access token.
+type AccessToken struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	TokenVersion int32
+	Roles        []string
+	ClientID     string
+	Scopes       []string
+	IssuedAt     time.Time
+	ExpiresAt    time.Time
+}
+
+func (k *Keyring) MakeJWT(userID uuid.UUID, tokenVersion int32, roles []string, expiresIn time.Duration) (string, error) {
+	return k.sign(userID, expiresIn, AccessClaims{
+		TokenVersion: tokenVersion,
+		Roles:        roles,
+	})
+}
+
+// MakeClientJWT issues an access token for an OAuth client acting for the
+// user. It carries scopes instead of roles.
+func (k *Keyring) MakeClientJWT(userID uuid.UUID, tokenVersion int32, clientID string, scopes []string, expiresIn time.Duration) (string, error) {
+	return k.sign(userID, expiresIn, AccessClaims{
+		TokenVersion: tokenVersion,
+		ClientID:     clientID,
+		Scope:        FormatScope(scopes),
+	})
+}
+
+func (k *Keyring) sign(userID uuid.UUID, expiresIn time.Duration, claims AccessClaims) (string, error) {
+	now := time.Now().UTC()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Issuer:    "chirpy",
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		Subject:   userID.String(),
+	}
+
+	key := k.active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.Private)
+}
+
+func (k *Keyring) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	token, err := k.ParseAccessToken(tokenString)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return token.UserID, nil
+}
+
+// ParseAccessToken validates a token and returns what it says. Tokens from
+// before jti was introduced come back with a nil ID and version 0.
+func (k *Keyring) ParseAccessToken(tokenString string) (AccessToken, error) {
+	claims := AccessClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, &claims, k.keyFunc, jwt.WithIssuer("chirpy"), jwt.WithExpirationRequired())
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	token := AccessToken{
+		UserID:       userID,
+		TokenVersion: claims.TokenVersion,
+		Roles:        claims.Roles,
+		ClientID:     claims.ClientID,
+		ExpiresAt:    claims.ExpiresAt.Time,
+	}
+	if claims.Scope != "" {
+		token.Scopes = ParseScope(claims.Scope)
+	}
+	if claims.IssuedAt != nil {
+		token.IssuedAt = claims.IssuedAt.Time
+	}
+	if claims.ID != "" {
+		token.ID, err = uuid.Parse(claims.ID)
+		if err != nil {
+			return AccessToken{}, fmt.Errorf("invalid token ID: %w", err)
+		}
+	}
+
+	k.mu.RLock()
+	revocations := k.revocations
+	k.mu.RUnlock()
+	if revocations != nil && revocations.Revoked(token) {
+		return AccessToken{}, ErrTokenRevoked
+	}
+
+	return token, nil
+}
+
+func (k *Keyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if len(k.legacySecret) == 0 || token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("token has no key ID")
+		}
+		return k.legacySecret, nil
+	}
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+	}
+	return key.Private.Public(), nil
+}
+
+// JWK is the public half of a signing key in RFC 7517 form.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS publishes every key in the ring, not just the active one, so tokens
+// signed before a rotation can still be verified by other services.
+func (k *Keyring) JWKS() JWKS {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range k.keys {
+		jwk := JWK{
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: key.Method.Alg(),
+		}
+		switch public := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestKeyringMakeAndValidateJWT(t *testing.T) {
+	edKey, err := GenerateSigningKey("ed-1")
+	if err != nil {
+		t.Fatalf("GenerateSigningKey returned error: %v", err)
+	}
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey returned error: %v", err)
+	}
+	rsaKey, err := NewSigningKey("rsa-1", rsaPrivate)
+	if err != nil {
+		t.Fatalf("NewSigningKey returned error: %v", err)
+	}
+
+	for _, key := range []*SigningKey{edKey, rsaKey} {
+		t.Run(key.Method.Alg(), func(t *testing.T) {
+			keyring := NewKeyring(key)
+			userID := uuid.New()
+			token, err := keyring.MakeJWT(userID, 0, nil, time.Minute)
+			if err != nil {
+				t.Fatalf("MakeJWT returned error: %v", err)
+			}
+
+			gotID, err := keyring.ValidateJWT(token)
+			if err != nil {
+				t.Fatalf("ValidateJWT returned error for valid token: %v", err)
+			}
+			if gotID != userID {
+				t.Fatalf("ValidateJWT returned wrong user id: got %v want %v", gotID, userID)
+			}
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey, _ := GenerateSigningKey("2026-01")
+	newKey, _ := GenerateSigningKey("2026-02")
+	keyring := NewKeyring(oldKey)
+	userID := uuid.New()
+
+	oldToken, err := keyring.MakeJWT(userID, 0, nil, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT returned error: %v", err)
+	}
+
+	keyring.Add(newKey)
+	if err := keyring.Activate(newKey.ID); err != nil {
+		t.Fatalf("Activate returned error: %v", err)
+	}
+
+	if _, err := keyring.ValidateJWT(oldToken); err != nil {
+		t.Fatalf("token signed by the previous key was rejected: %v", err)
+	}
+
+	if err := keyring.Remove(oldKey.ID); err != nil {
+		t.Fatalf("Remove returned error: %v", err)
+	}
+	if _, err := keyring.ValidateJWT(oldToken); err == nil {
+		t.Fatal("token signed by a removed key was accepted")
+	}
+	if err := keyring.Remove(newKey.ID); err == nil {
+		t.Fatal("Remove allowed removing the active key")
+	}
+}
+
+func TestKeyringLegacySecret(t *testing.T) {
+	key, _ := GenerateSigningKey("current")
+	keyring := NewKeyring(key)
+	userID := uuid.New()
+
+	legacyToken, err := MakeJWT(userID, "legacy-secret", time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT returned error: %v", err)
+	}
+
+	if _, err := keyring.ValidateJWT(legacyToken); err == nil {
+		t.Fatal("HS256 token was accepted without a legacy secret")
+	}
+
+	keyring.SetLegacySecret("legacy-secret")
+	gotID, err := keyring.ValidateJWT(legacyToken)
+	if err != nil {
+		t.Fatalf("ValidateJWT rejected legacy token: %v", err)
+	}
+	if gotID != userID {
+		t.Fatalf("ValidateJWT returned wrong user id: got %v want %v", gotID, userID)
+	}
+}
+
+func TestKeyringJWKS(t *testing.T) {
+	key, _ := GenerateSigningKey("ed-1")
+	keyring := NewKeyring(key)
+
+	set := keyring.JWKS()
+	if len(set.Keys) != 1 {
+		t.Fatalf("expected 1 key, got %d", len(set.Keys))
+	}
+	jwk := set.Keys[0]
+	if jwk.KeyID != "ed-1" || jwk.KeyType != "OKP" || jwk.Algorithm != "EdDSA" || jwk.X == "" {
+		t.Fatalf("unexpected JWK: %+v", jwk)
+	}
+}
+
+func TestAccessTokenRoles(t *testing.T) {
+	key, _ := GenerateSigningKey("k1")
+	keyring := NewKeyring(key)
+	userID := uuid.New()
+
+	tokenString, err := keyring.MakeJWT(userID, 2, []string{"admin", "moderator"}, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT returned error: %v", err)
+	}
+	token, err := keyring.ParseAccessToken(tokenString)
+	if err != nil {
+		t.Fatalf("ParseAccessToken returned error: %v", err)
+	}
+	if len(token.Roles) != 2 || token.Roles[0] != "admin" || token.Roles[1] != "moderator" {
+		t.Fatalf("roles = %v, want [admin moderator]", token.Roles)
+	}
+
+	tokenString, _ = keyring.MakeJWT(userID, 2, nil, time.Minute)
+	token, err = keyring.ParseAccessToken(tokenString)
+	if err != nil {
+		t.Fatalf("ParseAccessToken returned error: %v", err)
+	}
+	if len(token.Roles) != 0 {
+		t.Fatalf("roles = %v, want none", token.Roles)
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// CodeChallengeMethodS256 is the only PKCE method accepted. With "plain",
+// anyone who sees the authorization request could redeem the code.
+const CodeChallengeMethodS256 = "S256"
+
+var ErrInvalidCodeVerifier = errors.New("code verifier doesn't match the challenge")
+
+// CodeChallenge is the S256 challenge for verifier, as in RFC 7636.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// ValidCodeChallenge reports whether challenge looks like an S256
+// challenge: an unpadded base64url SHA-256.
+func ValidCodeChallenge(challenge string) bool {
+	if len(challenge) != 43 {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil
+}
+
+// VerifyPKCE checks the verifier a client sends with the authorization
+// code against the challenge it sent when asking for the code.
+func VerifyPKCE(verifier, challenge string) error {
+	if !validCodeVerifier(verifier) {
+		return ErrInvalidCodeVerifier
+	}
+	if subtle.ConstantTimeCompare([]byte(CodeChallenge(verifier)), []byte(challenge)) != 1 {
+		return ErrInvalidCodeVerifier
+	}
+	return nil
+}
+
+// validCodeVerifier follows RFC 7636 section 4.1: 43 to 128 unreserved
+// characters.
+func validCodeVerifier(verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ParseScope splits an OAuth scope parameter, dropping repeats.
+func ParseScope(scope string) []string {
+	scopes := []string{}
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// OAuthRefreshTokenPrefix marks refresh tokens issued to OAuth clients,
+// which are stored hashed unlike those of logins.
+const OAuthRefreshTokenPrefix = "chirpy_ort_"
+
+func MakeOAuthRefreshToken() (string, error) {
+	token, err := MakeToken()
+	if err != nil {
+		return "", err
+	}
+	return OAuthRefreshTokenPrefix + token, nil
+}
+
+func IsOAuthRefreshToken(token string) bool {
+	return strings.HasPrefix(token, OAuthRefreshTokenPrefix)
+}
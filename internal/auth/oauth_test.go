@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// From RFC 7636, appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if got := CodeChallenge(verifier); got != challenge {
+		t.Fatalf("CodeChallenge = %q, want %q", got, challenge)
+	}
+	if !ValidCodeChallenge(challenge) {
+		t.Fatal("ValidCodeChallenge rejected the RFC challenge")
+	}
+	if err := VerifyPKCE(verifier, challenge); err != nil {
+		t.Fatalf("VerifyPKCE returned error: %v", err)
+	}
+
+	cases := map[string]string{
+		"wrong verifier": strings.Replace(verifier, "d", "e", 1),
+		"too short":      "abc",
+		"too long":       strings.Repeat("a", 129),
+		"bad character":  verifier[:42] + "+",
+	}
+	for name, v := range cases {
+		if err := VerifyPKCE(v, challenge); err == nil {
+			t.Errorf("%s: VerifyPKCE accepted %q", name, v)
+		}
+	}
+
+	if ValidCodeChallenge(verifier[:40]) || ValidCodeChallenge(challenge[:42]+"=") {
+		t.Fatal("ValidCodeChallenge accepted a malformed challenge")
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	got := ParseScope("  chirps:read chirps:write\tchirps:read ")
+	if FormatScope(got) != "chirps:read chirps:write" {
+		t.Fatalf("ParseScope = %v", got)
+	}
+	if len(ParseScope("")) != 0 {
+		t.Fatal("ParseScope of an empty string isn't empty")
+	}
+}
+
+func TestMakeClientJWT(t *testing.T) {
+	key, _ := GenerateSigningKey("k1")
+	keyring := NewKeyring(key)
+	userID := uuid.New()
+
+	tokenString, err := keyring.MakeClientJWT(userID, 1, "client-1", []string{ScopeChirpsRead}, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeClientJWT returned error: %v", err)
+	}
+	token, err := keyring.ParseAccessToken(tokenString)
+	if err != nil {
+		t.Fatalf("ParseAccessToken returned error: %v", err)
+	}
+	if token.UserID != userID || token.ClientID != "client-1" || token.TokenVersion != 1 {
+		t.Fatalf("unexpected token %+v", token)
+	}
+	if len(token.Scopes) != 1 || token.Scopes[0] != ScopeChirpsRead || len(token.Roles) != 0 {
+		t.Fatalf("scopes = %v, roles = %v", token.Scopes, token.Roles)
+	}
+	if token.IssuedAt.IsZero() || !token.ExpiresAt.After(token.IssuedAt) {
+		t.Fatalf("issued at %s, expires at %s", token.IssuedAt, token.ExpiresAt)
+	}
+
+	tokenString, _ = keyring.MakeJWT(userID, 1, nil, time.Minute)
+	token, err = keyring.ParseAccessToken(tokenString)
+	if err != nil {
+		t.Fatalf("ParseAccessToken returned error: %v", err)
+	}
+	if token.ClientID != "" || token.Scopes != nil {
+		t.Fatalf("login token came back with client %q and scopes %v", token.ClientID, token.Scopes)
+	}
+}
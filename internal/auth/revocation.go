@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevocationChecker reports whether an otherwise valid access token was
+// revoked before it expired.
+type RevocationChecker interface {
+	Revoked(token AccessToken) bool
+}
+
+// RevocationList is an in-memory denylist of access tokens. It only needs
+// to remember a revocation until the tokens it covers expire, so it stays
+// small. Filling it from shared storage is up to the caller.
+type RevocationList struct {
+	mu sync.RWMutex
+	// tokens maps a jti to when that token expires.
+	tokens map[uuid.UUID]time.Time
+	// versions maps a user to the lowest token version still accepted and
+	// when the last token below it expires.
+	versions map[uuid.UUID]versionFloor
+}
+
+type versionFloor struct {
+	minVersion int32
+	expiresAt  time.Time
+}
+
+func NewRevocationList() *RevocationList {
+	return &RevocationList{
+		tokens:   map[uuid.UUID]time.Time{},
+		versions: map[uuid.UUID]versionFloor{},
+	}
+}
+
+// RevokeToken refuses the token with the given jti until it expires.
+func (l *RevocationList) RevokeToken(id uuid.UUID, expiresAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if expiresAt.After(l.tokens[id]) {
+		l.tokens[id] = expiresAt
+	}
+}
+
+// RevokeVersionsBelow refuses the user's tokens issued with a version lower
+// than minVersion. expiresAt is when the last of them runs out.
+func (l *RevocationList) RevokeVersionsBelow(userID uuid.UUID, minVersion int32, expiresAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	floor := l.versions[userID]
+	if minVersion > floor.minVersion {
+		floor.minVersion = minVersion
+	}
+	if expiresAt.After(floor.expiresAt) {
+		floor.expiresAt = expiresAt
+	}
+	l.versions[userID] = floor
+}
+
+func (l *RevocationList) Revoked(token AccessToken) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if _, ok := l.tokens[token.ID]; ok && token.ID != uuid.Nil {
+		return true
+	}
+	floor, ok := l.versions[token.UserID]
+	return ok && token.TokenVersion < floor.minVersion
+}
+
+// Prune forgets revocations whose tokens have all expired by now.
+func (l *RevocationList) Prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for id, expiresAt := range l.tokens {
+		if !expiresAt.After(now) {
+			delete(l.tokens, id)
+		}
+	}
+	for userID, floor := range l.versions {
+		if !floor.expiresAt.After(now) {
+			delete(l.versions, userID)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRevocationListTokens(t *testing.T) {
+	list := NewRevocationList()
+	now := time.Now()
+	revoked := AccessToken{ID: uuid.New(), UserID: uuid.New()}
+	other := AccessToken{ID: uuid.New(), UserID: revoked.UserID}
+
+	list.RevokeToken(revoked.ID, now.Add(time.Hour))
+	if !list.Revoked(revoked) {
+		t.Error("revoked token was accepted")
+	}
+	if list.Revoked(other) {
+		t.Error("another token of the same user was refused")
+	}
+	if list.Revoked(AccessToken{UserID: revoked.UserID}) {
+		t.Error("a token without a jti was refused")
+	}
+
+	list.Prune(now.Add(2 * time.Hour))
+	if list.Revoked(revoked) {
+		t.Error("revocation outlived the token")
+	}
+}
+
+func TestRevocationListVersions(t *testing.T) {
+	list := NewRevocationList()
+	now := time.Now()
+	userID := uuid.New()
+
+	list.RevokeVersionsBelow(userID, 2, now.Add(time.Hour))
+	// A late, older revocation mustn't lower the floor.
+	list.RevokeVersionsBelow(userID, 1, now.Add(time.Minute))
+
+	for version, want := range map[int32]bool{0: true, 1: true, 2: false, 3: false} {
+		got := list.Revoked(AccessToken{ID: uuid.New(), UserID: userID, TokenVersion: version})
+		if got != want {
+			t.Errorf("version %d revoked = %t, want %t", version, got, want)
+		}
+	}
+	if list.Revoked(AccessToken{ID: uuid.New(), UserID: uuid.New()}) {
+		t.Error("another user's token was refused")
+	}
+
+	list.Prune(now.Add(30 * time.Minute))
+	if !list.Revoked(AccessToken{UserID: userID, TokenVersion: 1}) {
+		t.Error("floor was pruned before its tokens expired")
+	}
+	list.Prune(now.Add(time.Hour))
+	if list.Revoked(AccessToken{UserID: userID, TokenVersion: 1}) {
+		t.Error("floor outlived its tokens")
+	}
+}
+
+func TestKeyringRevocationChecker(t *testing.T) {
+	key, _ := GenerateSigningKey("current")
+	keyring := NewKeyring(key)
+	list := NewRevocationList()
+	keyring.SetRevocationChecker(list)
+	userID := uuid.New()
+
+	tokenString, err := keyring.MakeJWT(userID, 3, nil, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT returned error: %v", err)
+	}
+	token, err := keyring.ParseAccessToken(tokenString)
+	if err != nil {
+		t.Fatalf("ParseAccessToken returned error: %v", err)
+	}
+	if token.ID == uuid.Nil || token.UserID != userID || token.TokenVersion != 3 {
+		t.Fatalf("ParseAccessToken returned %+v", token)
+	}
+
+	list.RevokeToken(token.ID, token.ExpiresAt)
+	if _, err := keyring.ValidateJWT(tokenString); err != ErrTokenRevoked {
+		t.Fatalf("ValidateJWT error = %v, want ErrTokenRevoked", err)
+	}
+
+	newer, _ := keyring.MakeJWT(userID, 4, nil, time.Minute)
+	list.RevokeVersionsBelow(userID, 4, time.Now().Add(time.Minute))
+	if _, err := keyring.ValidateJWT(newer); err != nil {
+		t.Fatalf("token at the new version was refused: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+)
+
+// Scopes limit what a personal access token or an OAuth client may do.
+// Access tokens from a login aren't scoped; they can do everything the user
+// can.
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeProfileRead  = "profile:read"
+	ScopeProfileWrite = "profile:write"
+)
+
+var Scopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeProfileRead, ScopeProfileWrite}
+
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// HasScope reports whether granted covers want. Write access includes read
+// access to the same resource.
+func HasScope(granted []string, want string) bool {
+	if slices.Contains(granted, want) {
+		return true
+	}
+	resource, action, ok := strings.Cut(want, ":")
+	return ok && action == "read" && slices.Contains(granted, resource+":write")
+}
+
+// PersonalAccessTokenPrefix tells personal access tokens apart from JWTs
+// and makes them easy to spot in leaked text.
+const PersonalAccessTokenPrefix = "chirpy_pat_"
+
+func MakePersonalAccessToken() (string, error) {
+	token, err := MakeToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + token, nil
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
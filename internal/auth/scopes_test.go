@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHasScope(t *testing.T) {
+	cases := []struct {
+		granted []string
+		want    string
+		ok      bool
+	}{
+		{[]string{ScopeChirpsWrite}, ScopeChirpsWrite, true},
+		{[]string{ScopeChirpsWrite}, ScopeChirpsRead, true},
+		{[]string{ScopeChirpsRead}, ScopeChirpsWrite, false},
+		{[]string{ScopeProfileWrite}, ScopeChirpsRead, false},
+		{nil, ScopeProfileRead, false},
+	}
+	for _, tc := range cases {
+		if got := HasScope(tc.granted, tc.want); got != tc.ok {
+			t.Errorf("HasScope(%v, %q) = %t, want %t", tc.granted, tc.want, got, tc.ok)
+		}
+	}
+}
+
+func TestValidScope(t *testing.T) {
+	for _, scope := range Scopes {
+		if !ValidScope(scope) {
+			t.Errorf("ValidScope(%q) = false", scope)
+		}
+	}
+	if ValidScope("chirps:admin") {
+		t.Error("ValidScope accepted an unknown scope")
+	}
+}
+
+func TestMakePersonalAccessToken(t *testing.T) {
+	a, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("MakePersonalAccessToken returned error: %v", err)
+	}
+	b, _ := MakePersonalAccessToken()
+	if a == b {
+		t.Fatal("two tokens are equal")
+	}
+	if !IsPersonalAccessToken(a) || strings.Count(a, ".") != 0 {
+		t.Fatalf("token %q doesn't look like a personal access token", a)
+	}
+	if IsPersonalAccessToken("eyJhbGciOi.eyJzdWIi.sig") {
+		t.Fatal("a JWT was taken for a personal access token")
+	}
+}
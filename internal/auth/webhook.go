@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookSignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>".
+// The MAC covers the timestamp, a dot and the raw request body, so a
+// captured request can't be replayed with a fresh timestamp.
+const WebhookSignatureHeader = "Polka-Signature"
+
+var (
+	ErrWebhookSignatureMissing = errors.New("missing webhook signature")
+	ErrWebhookSignatureInvalid = errors.New("invalid webhook signature")
+	ErrWebhookSignatureExpired = errors.New("webhook timestamp is outside the replay window")
+)
+
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", t, webhookMAC(secret, t, body))
+}
+
+// VerifyWebhookSignature checks the signature header against body and
+// rejects timestamps more than window away from now in either direction.
+// Several v1 entries may be present while the secret is being rotated.
+func VerifyWebhookSignature(headers http.Header, secret string, body []byte, window time.Duration, now time.Time) error {
+	header := headers.Get(WebhookSignatureHeader)
+	if header == "" {
+		return ErrWebhookSignatureMissing
+	}
+
+	t := ""
+	signatures := []string{}
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrWebhookSignatureInvalid
+		}
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if t == "" || len(signatures) == 0 {
+		return ErrWebhookSignatureInvalid
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return ErrWebhookSignatureInvalid
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > window || age < -window {
+		return ErrWebhookSignatureExpired
+	}
+
+	expected := []byte(webhookMAC(secret, t, body))
+	for _, signature := range signatures {
+		if hmac.Equal(expected, []byte(signature)) {
+			return nil
+		}
+	}
+	return ErrWebhookSignatureInvalid
+}
+
+func webhookMAC(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		wantErr error
+	}{
+		{
+			name:   "valid signature",
+			header: SignWebhook("secret", now.Add(-time.Minute), body),
+			body:   body,
+		},
+		{
+			name:   "one of several signatures matches",
+			header: SignWebhook("old", now, body) + ",v1=" + webhookMAC("secret", "1700000000", body),
+			body:   body,
+		},
+		{
+			name:    "missing header",
+			header:  "",
+			body:    body,
+			wantErr: ErrWebhookSignatureMissing,
+		},
+		{
+			name:    "wrong secret",
+			header:  SignWebhook("other", now, body),
+			body:    body,
+			wantErr: ErrWebhookSignatureInvalid,
+		},
+		{
+			name:    "tampered body",
+			header:  SignWebhook("secret", now, body),
+			body:    []byte(`{"id":"evt_1","event":"user.downgraded"}`),
+			wantErr: ErrWebhookSignatureInvalid,
+		},
+		{
+			name:    "too old",
+			header:  SignWebhook("secret", now.Add(-10*time.Minute), body),
+			body:    body,
+			wantErr: ErrWebhookSignatureExpired,
+		},
+		{
+			name:    "from the future",
+			header:  SignWebhook("secret", now.Add(10*time.Minute), body),
+			body:    body,
+			wantErr: ErrWebhookSignatureExpired,
+		},
+		{
+			name:    "malformed",
+			header:  "v1=abc",
+			body:    body,
+			wantErr: ErrWebhookSignatureInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			if tt.header != "" {
+				headers.Set(WebhookSignatureHeader, tt.header)
+			}
+			err := VerifyWebhookSignature(headers, "secret", tt.body, 5*time.Minute, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyWebhookSignature() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
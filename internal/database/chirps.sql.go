@@ -7,29 +7,54 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countChirpsByAuthorSince = `-- name: CountChirpsByAuthorSince :one
+
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+AND created_at >= $2
+AND rechirp_of_id IS NULL
+`
+
+type CountChirpsByAuthorSinceParams struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) CountChirpsByAuthorSince(ctx context.Context, arg CountChirpsByAuthorSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsByAuthorSince, arg.UserID, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, parent_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body     string
+	UserID   uuid.UUID
+	ParentID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.ParentID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,10 +62,95 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.SearchVector,
+		&i.ParentID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.RechirpOfID,
+		&i.EditedAt,
 	)
 	return i, err
 }
 
+const createRechirp = `-- name: CreateRechirp :one
+
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, rechirp_of_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    '',
+    $1,
+    $2
+)
+ON CONFLICT (user_id, rechirp_of_id) WHERE rechirp_of_id IS NOT NULL DO NOTHING
+RETURNING id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at
+`
+
+type CreateRechirpParams struct {
+	UserID      uuid.UUID
+	RechirpOfID uuid.NullUUID
+}
+
+func (q *Queries) CreateRechirp(ctx context.Context, arg CreateRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createRechirp, arg.UserID, arg.RechirpOfID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ParentID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.RechirpOfID,
+		&i.EditedAt,
+	)
+	return i, err
+}
+
+const decrementLikeCount = `-- name: DecrementLikeCount :exec
+
+UPDATE chirps
+SET like_count = like_count - 1
+WHERE id = $1
+`
+
+func (q *Queries) DecrementLikeCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, decrementLikeCount, id)
+	return err
+}
+
+const decrementRechirpCount = `-- name: DecrementRechirpCount :exec
+
+UPDATE chirps
+SET rechirp_count = rechirp_count - 1
+WHERE id = $1
+`
+
+func (q *Queries) DecrementRechirpCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, decrementRechirpCount, id)
+	return err
+}
+
+const decrementReplyCount = `-- name: DecrementReplyCount :exec
+
+UPDATE chirps
+SET reply_count = reply_count - 1
+WHERE id = $1
+`
+
+func (q *Queries) DecrementReplyCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, decrementReplyCount, id)
+	return err
+}
+
 const deleteChirp = `-- name: DeleteChirp :exec
 
 DELETE FROM chirps
@@ -52,9 +162,29 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteRechirp = `-- name: DeleteRechirp :execrows
+
+DELETE FROM chirps
+WHERE user_id = $1
+AND rechirp_of_id = $2
+`
+
+type DeleteRechirpParams struct {
+	UserID      uuid.UUID
+	RechirpOfID uuid.NullUUID
+}
+
+func (q *Queries) DeleteRechirp(ctx context.Context, arg DeleteRechirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRechirp, arg.UserID, arg.RechirpOfID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getChirp = `-- name: GetChirp :one
 
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM chirps 
 WHERE id = $1
 `
 
@@ -67,13 +197,99 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.SearchVector,
+		&i.ParentID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.RechirpOfID,
+		&i.EditedAt,
+	)
+	return i, err
+}
+
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+
+WITH RECURSIVE ancestors AS (
+    SELECT chirps.*, 1 AS depth FROM chirps
+    WHERE chirps.id = (SELECT parent_id FROM chirps AS child WHERE child.id = $1)
+    UNION ALL
+    SELECT chirps.*, ancestors.depth + 1 FROM chirps
+    JOIN ancestors ON chirps.id = ancestors.parent_id
+)
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM ancestors
+ORDER BY depth DESC
+`
+
+func (q *Queries) GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ParentID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.RechirpOfID,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpForUpdate = `-- name: GetChirpForUpdate :one
+
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM chirps
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetChirpForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ParentID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.RechirpOfID,
+		&i.EditedAt,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
 
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM chirps 
 ORDER BY created_at ASC
 `
 
@@ -92,6 +308,14 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.SearchVector,
+			&i.ParentID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.RechirpOfID,
+			&i.EditedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -108,7 +332,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 
 const getChirpsByAuthor = `-- name: GetChirpsByAuthor :many
 
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM chirps
 WHERE user_id = $1
 ORDER BY created_at ASC
 `
@@ -128,6 +352,184 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.SearchVector,
+			&i.ParentID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.RechirpOfID,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM chirps
+WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ParentID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.RechirpOfID,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRechirp = `-- name: GetRechirp :one
+
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM chirps
+WHERE user_id = $1
+AND rechirp_of_id = $2
+`
+
+type GetRechirpParams struct {
+	UserID      uuid.UUID
+	RechirpOfID uuid.NullUUID
+}
+
+func (q *Queries) GetRechirp(ctx context.Context, arg GetRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getRechirp, arg.UserID, arg.RechirpOfID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ParentID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.RechirpOfID,
+		&i.EditedAt,
+	)
+	return i, err
+}
+
+const incrementLikeCount = `-- name: IncrementLikeCount :exec
+
+UPDATE chirps
+SET like_count = like_count + 1
+WHERE id = $1
+`
+
+func (q *Queries) IncrementLikeCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, incrementLikeCount, id)
+	return err
+}
+
+const incrementRechirpCount = `-- name: IncrementRechirpCount :exec
+
+UPDATE chirps
+SET rechirp_count = rechirp_count + 1
+WHERE id = $1
+`
+
+func (q *Queries) IncrementRechirpCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, incrementRechirpCount, id)
+	return err
+}
+
+const incrementReplyCount = `-- name: IncrementReplyCount :exec
+
+UPDATE chirps
+SET reply_count = reply_count + 1
+WHERE id = $1
+`
+
+func (q *Queries) IncrementReplyCount(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, incrementReplyCount, id)
+	return err
+}
+
+const listChirpDescendants = `-- name: ListChirpDescendants :many
+
+WITH RECURSIVE descendants AS (
+    SELECT chirps.*, 1 AS depth FROM chirps
+    WHERE chirps.parent_id = ANY($1::uuid[])
+    UNION ALL
+    SELECT chirps.*, descendants.depth + 1 FROM chirps
+    JOIN descendants ON chirps.parent_id = descendants.id
+    WHERE descendants.depth < $2::int
+)
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM descendants
+ORDER BY depth ASC, created_at ASC, id ASC
+LIMIT $3
+`
+
+type ListChirpDescendantsParams struct {
+	ParentIds []uuid.UUID
+	MaxDepth  int32
+	Limit     int32
+}
+
+func (q *Queries) ListChirpDescendants(ctx context.Context, arg ListChirpDescendantsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpDescendants, pq.Array(arg.ParentIds), arg.MaxDepth, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ParentID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.RechirpOfID,
+			&i.EditedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -141,3 +543,469 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const listChirpReplies = `-- name: ListChirpReplies :many
+
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM chirps
+WHERE parent_id = $1
+AND ($2::timestamp IS NULL
+    OR (created_at, id) > ($2, $3::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type ListChirpRepliesParams struct {
+	ParentID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+func (q *Queries) ListChirpReplies(ctx context.Context, arg ListChirpRepliesParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpReplies,
+		arg.ParentID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ParentID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.RechirpOfID,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpRepliesReverse = `-- name: ListChirpRepliesReverse :many
+
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM chirps
+WHERE parent_id = $1
+AND ($2::timestamp IS NULL
+    OR (created_at, id) < ($2, $3::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListChirpRepliesReverseParams struct {
+	ParentID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+func (q *Queries) ListChirpRepliesReverse(ctx context.Context, arg ListChirpRepliesReverseParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpRepliesReverse,
+		arg.ParentID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ParentID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.RechirpOfID,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM chirps
+WHERE deleted_at IS NULL
+AND ($1::uuid IS NULL OR user_id = $1)
+AND ($2::timestamp IS NULL OR created_at >= $2)
+AND ($3::timestamp IS NULL OR created_at < $3)
+AND ($4::timestamp IS NULL
+    OR (created_at, id) > ($4, $5::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $6
+`
+
+type ListChirpsAscParams struct {
+	AuthorID        uuid.NullUUID
+	Since           sql.NullTime
+	Until           sql.NullTime
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ParentID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.RechirpOfID,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM chirps
+WHERE deleted_at IS NULL
+AND ($1::uuid IS NULL OR user_id = $1)
+AND ($2::timestamp IS NULL OR created_at >= $2)
+AND ($3::timestamp IS NULL OR created_at < $3)
+AND ($4::timestamp IS NULL
+    OR (created_at, id) < ($4, $5::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $6
+`
+
+type ListChirpsDescParams struct {
+	AuthorID        uuid.NullUUID
+	Since           sql.NullTime
+	Until           sql.NullTime
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.ParentID,
+			&i.ReplyCount,
+			&i.DeletedAt,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.RechirpOfID,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirps = `-- name: SearchChirps :many
+
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.parent_id, chirps.reply_count, chirps.deleted_at, chirps.like_count, chirps.rechirp_count, chirps.rechirp_of_id, chirps.edited_at,
+    ts_rank_cd(search_vector, to_tsquery('english', $1))::real AS rank,
+    ts_headline('english', body, to_tsquery('english', $1),
+        'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) || ', MaxFragments=2, MaxWords=20, MinWords=5')::text AS headline
+FROM chirps
+WHERE search_vector @@ to_tsquery('english', $1)
+AND deleted_at IS NULL
+AND rechirp_of_id IS NULL
+AND ($2::uuid IS NULL OR user_id = $2)
+AND ($3::timestamp IS NULL OR created_at >= $3)
+AND ($4::timestamp IS NULL OR created_at < $4)
+AND ($5::real IS NULL
+    OR (ts_rank_cd(search_vector, to_tsquery('english', $1))::real, created_at, id)
+        < ($5, $6::timestamp, $7::uuid))
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT $8
+`
+
+type SearchChirpsParams struct {
+	Query           string
+	AuthorID        uuid.NullUUID
+	Since           sql.NullTime
+	Until           sql.NullTime
+	CursorRank      sql.NullFloat64
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+type SearchChirpsRow struct {
+	Chirp    Chirp
+	Rank     float32
+	Headline string
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.CursorRank,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.SearchVector,
+			&i.Chirp.ParentID,
+			&i.Chirp.ReplyCount,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.LikeCount,
+			&i.Chirp.RechirpCount,
+			&i.Chirp.RechirpOfID,
+			&i.Chirp.EditedAt,
+			&i.Rank,
+			&i.Headline,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirpsReverse = `-- name: SearchChirpsReverse :many
+
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.parent_id, chirps.reply_count, chirps.deleted_at, chirps.like_count, chirps.rechirp_count, chirps.rechirp_of_id, chirps.edited_at,
+    ts_rank_cd(search_vector, to_tsquery('english', $1))::real AS rank,
+    ts_headline('english', body, to_tsquery('english', $1),
+        'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) || ', MaxFragments=2, MaxWords=20, MinWords=5')::text AS headline
+FROM chirps
+WHERE search_vector @@ to_tsquery('english', $1)
+AND deleted_at IS NULL
+AND rechirp_of_id IS NULL
+AND ($2::uuid IS NULL OR user_id = $2)
+AND ($3::timestamp IS NULL OR created_at >= $3)
+AND ($4::timestamp IS NULL OR created_at < $4)
+AND ($5::real IS NULL
+    OR (ts_rank_cd(search_vector, to_tsquery('english', $1))::real, created_at, id)
+        > ($5, $6::timestamp, $7::uuid))
+ORDER BY rank ASC, created_at ASC, id ASC
+LIMIT $8
+`
+
+type SearchChirpsReverseParams struct {
+	Query           string
+	AuthorID        uuid.NullUUID
+	Since           sql.NullTime
+	Until           sql.NullTime
+	CursorRank      sql.NullFloat64
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	Limit           int32
+}
+
+type SearchChirpsReverseRow struct {
+	Chirp    Chirp
+	Rank     float32
+	Headline string
+}
+
+func (q *Queries) SearchChirpsReverse(ctx context.Context, arg SearchChirpsReverseParams) ([]SearchChirpsReverseRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirpsReverse,
+		arg.Query,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.CursorRank,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsReverseRow
+	for rows.Next() {
+		var i SearchChirpsReverseRow
+		if err := rows.Scan(
+			&i.Chirp.ID,
+			&i.Chirp.CreatedAt,
+			&i.Chirp.UpdatedAt,
+			&i.Chirp.Body,
+			&i.Chirp.UserID,
+			&i.Chirp.SearchVector,
+			&i.Chirp.ParentID,
+			&i.Chirp.ReplyCount,
+			&i.Chirp.DeletedAt,
+			&i.Chirp.LikeCount,
+			&i.Chirp.RechirpCount,
+			&i.Chirp.RechirpOfID,
+			&i.Chirp.EditedAt,
+			&i.Rank,
+			&i.Headline,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const tombstoneChirp = `-- name: TombstoneChirp :exec
+
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) TombstoneChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, tombstoneChirp, id)
+	return err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+
+UPDATE chirps
+SET body = $2, updated_at = NOW(), edited_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.ParentID,
+		&i.ReplyCount,
+		&i.DeletedAt,
+		&i.LikeCount,
+		&i.RechirpCount,
+		&i.RechirpOfID,
+		&i.EditedAt,
+	)
+	return i, err
+}
@@ -6,33 +6,267 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AccessTokenRevocation struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	TokenID    uuid.NullUUID
+	MinVersion sql.NullInt32
+	ExpiresAt  time.Time
+}
+
 type Chirp struct {
-	ID        uuid.UUID
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	SearchVector interface{}
+	ParentID     uuid.NullUUID
+	ReplyCount   int32
+	DeletedAt    sql.NullTime
+	LikeCount    int32
+	RechirpCount int32
+	RechirpOfID  uuid.NullUUID
+	EditedAt     sql.NullTime
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	CreatedAt  time.Time
+	ReplacedAt time.Time
+}
+
+type EmailOutbox struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	ToAddress     string
+	Subject       string
+	Body          string
+	SentAt        sql.NullTime
+	Attempts      int32
+	LastError     sql.NullString
+	NextAttemptAt time.Time
+}
+
+type EmailVerificationToken struct {
+	TokenHash string
 	UserID    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
 }
 
-type RefreshToken struct {
-	Token     string
+type FileserverHit struct {
+	ID   int32
+	Hits int64
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type LoginChallenge struct {
+	TokenHash string
+	UserID    uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+	ExpiresAt time.Time
+	Attempts  int32
+	UsedAt    sql.NullTime
+}
+
+type LoginThrottle struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+type OauthAuthorizationCode struct {
+	ID            uuid.UUID
+	CodeHash      string
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	CodeChallenge string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	OwnerID      uuid.UUID
+	Name         string
+	SecretHash   sql.NullString
+	RedirectUris []string
+	Scopes       []string
+	CreatedAt    time.Time
+	RevokedAt    sql.NullTime
+}
+
+type OauthRefreshToken struct {
+	ID        uuid.UUID
+	TokenHash string
+	ClientID  uuid.UUID
 	UserID    uuid.UUID
+	FamilyID  uuid.UUID
+	Scopes    []string
+	CreatedAt time.Time
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
 }
 
-type User struct {
+type OidcLogin struct {
+	StateHash    string
+	Nonce        string
+	CodeVerifier string
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+type OidcSignIn struct {
+	CodeHash  string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type RefreshToken struct {
+	Token      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	UserAgent  string
+	IpAddress  string
+	LastUsedAt time.Time
+}
+
+type Role struct {
+	Name        string
+	Description string
+}
+
+type RolePermission struct {
+	Role       string
+	Permission string
+}
+
+type Subscription struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	UserID           uuid.UUID
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+	GracePeriodEnd   sql.NullTime
+	LastEventAt      sql.NullTime
+}
+
+type SubscriptionEvent struct {
 	ID             uuid.UUID
+	SubscriptionID uuid.UUID
 	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	EventType      string
+	Status         string
+	PeriodEnd      time.Time
+	WebhookEventID uuid.NullUUID
+}
+
+type User struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     bool
+	ChirpyRedUntil  sql.NullTime
+	EmailVerifiedAt sql.NullTime
+	PendingEmail    sql.NullString
+	TokenVersion    int32
+}
+
+type UserIdentity struct {
+	Issuer      string
+	Subject     string
+	UserID      uuid.UUID
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+type UserRole struct {
+	UserID    uuid.UUID
+	Role      string
+	GrantedAt time.Time
+	GrantedBy uuid.NullUUID
+}
+
+type UserTotp struct {
+	UserID       uuid.UUID
+	Secret       string
+	CreatedAt    time.Time
+	ConfirmedAt  sql.NullTime
+	LastUsedStep int64
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID
+	EventID     string
+	EventType   string
+	Payload     json.RawMessage
+	ReceivedAt  time.Time
+	ProcessedAt sql.NullTime
+	Attempts    int32
+	LastError   sql.NullString
 }
@@ -7,10 +7,42 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const confirmUserEmail = `-- name: ConfirmUserEmail :one
+
+UPDATE users
+SET email = $2, pending_email = NULL, email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, chirpy_red_until, email_verified_at, pending_email, token_version
+`
+
+type ConfirmUserEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) ConfirmUserEmail(ctx context.Context, arg ConfirmUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, confirmUserEmail, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.ChirpyRedUntil,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.TokenVersion,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -20,7 +52,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, chirpy_red_until, email_verified_at, pending_email, token_version
 `
 
 type CreateUserParams struct {
@@ -38,13 +70,40 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.ChirpyRedUntil,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.TokenVersion,
+	)
+	return i, err
+}
+
+const getUser = `-- name: GetUser :one
+
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, chirpy_red_until, email_verified_at, pending_email, token_version FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.ChirpyRedUntil,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.TokenVersion,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
 
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, chirpy_red_until, email_verified_at, pending_email, token_version FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -57,26 +116,54 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.ChirpyRedUntil,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.TokenVersion,
 	)
 	return i, err
 }
 
-const updateUser = `-- name: UpdateUser :one
+const getUserPlan = `-- name: GetUserPlan :one
+
+SELECT users.chirpy_red_until, subscriptions.plan
+FROM users
+LEFT JOIN subscriptions ON subscriptions.user_id = users.id
+WHERE users.id = $1
+`
+
+type GetUserPlanRow struct {
+	ChirpyRedUntil sql.NullTime
+	Plan           sql.NullString
+}
+
+func (q *Queries) GetUserPlan(ctx context.Context, id uuid.UUID) (GetUserPlanRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserPlan, id)
+	var i GetUserPlanRow
+	err := row.Scan(
+		&i.ChirpyRedUntil,
+		&i.Plan,
+	)
+	return i, err
+}
+
+const setUserChirpyRedUntil = `-- name: SetUserChirpyRedUntil :one
 
 UPDATE users
-SET email = $1, hashed_password = $2, updated_at = NOW()
-WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SET chirpy_red_until = $1,
+    is_chirpy_red = COALESCE($1::timestamp > NOW(), false),
+    updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, chirpy_red_until, email_verified_at, pending_email, token_version
 `
 
-type UpdateUserParams struct {
-	Email          string
-	HashedPassword string
+type SetUserChirpyRedUntilParams struct {
+	ChirpyRedUntil sql.NullTime
 	ID             uuid.UUID
 }
 
-func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUser, arg.Email, arg.HashedPassword, arg.ID)
+func (q *Queries) SetUserChirpyRedUntil(ctx context.Context, arg SetUserChirpyRedUntilParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserChirpyRedUntil, arg.ChirpyRedUntil, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -85,20 +172,30 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.ChirpyRedUntil,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.TokenVersion,
 	)
 	return i, err
 }
 
-const upgradeUser = `-- name: UpgradeUser :one
+const updateUser = `-- name: UpdateUser :one
 
 UPDATE users
-SET is_chirpy_red = true, updated_at = NOW()
-WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SET hashed_password = $1, pending_email = COALESCE($2, pending_email), updated_at = NOW()
+WHERE id = $3
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, chirpy_red_until, email_verified_at, pending_email, token_version
 `
 
-func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) (User, error) {
-	row := q.db.QueryRowContext(ctx, upgradeUser, id)
+type UpdateUserParams struct {
+	HashedPassword string
+	PendingEmail   sql.NullString
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUser, arg.HashedPassword, arg.PendingEmail, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -107,6 +204,27 @@ func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.ChirpyRedUntil,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.TokenVersion,
 	)
 	return i, err
 }
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
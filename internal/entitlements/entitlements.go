@@ -0,0 +1,103 @@
+// Package entitlements decides what each plan may do. Plans and their
+// limits come from a JSON document so they can be tuned without a release;
+// default.json is used when none is configured.
+package entitlements
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+const (
+	FeatureEditChirps = "edit_chirps"
+)
+
+//go:embed default.json
+var defaultConfig []byte
+
+// Plan is the set of limits and capabilities granted by one plan. A zero
+// ChirpsPerHour means no limit.
+type Plan struct {
+	Name           string   `json:"-"`
+	MaxChirpLength int      `json:"max_chirp_length"`
+	ChirpsPerHour  int      `json:"chirps_per_hour"`
+	Features       []string `json:"features"`
+}
+
+func (p Plan) Allows(feature string) bool {
+	return slices.Contains(p.Features, feature)
+}
+
+type Entitlements struct {
+	defaultPlan string
+	plans       map[string]Plan
+}
+
+type config struct {
+	DefaultPlan string          `json:"default_plan"`
+	Plans       map[string]Plan `json:"plans"`
+}
+
+// Load reads plans from path, or the built-in ones when path is empty.
+func Load(path string) (*Entitlements, error) {
+	if path == "" {
+		return Parse(defaultConfig)
+	}
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(dat)
+}
+
+func Parse(dat []byte) (*Entitlements, error) {
+	cfg := config{}
+	err := json.Unmarshal(dat, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode plans: %w", err)
+	}
+
+	if cfg.DefaultPlan == "" {
+		return nil, errors.New("default_plan must be set")
+	}
+	if _, ok := cfg.Plans[cfg.DefaultPlan]; !ok {
+		return nil, fmt.Errorf("default plan %q is not defined", cfg.DefaultPlan)
+	}
+	for name, plan := range cfg.Plans {
+		if plan.MaxChirpLength < 1 {
+			return nil, fmt.Errorf("plan %q: max_chirp_length must be positive", name)
+		}
+		if plan.ChirpsPerHour < 0 {
+			return nil, fmt.Errorf("plan %q: chirps_per_hour can't be negative", name)
+		}
+		plan.Name = name
+		cfg.Plans[name] = plan
+	}
+
+	return &Entitlements{
+		defaultPlan: cfg.DefaultPlan,
+		plans:       cfg.Plans,
+	}, nil
+}
+
+// Default is the plan of users without an active subscription.
+func (e *Entitlements) Default() Plan {
+	return e.plans[e.defaultPlan]
+}
+
+// Resolve returns the plan a user is on. Inactive subscriptions and plans
+// missing from the configuration fall back to the default plan.
+func (e *Entitlements) Resolve(name string, active bool) Plan {
+	if !active {
+		return e.Default()
+	}
+	plan, ok := e.plans[name]
+	if !ok {
+		return e.Default()
+	}
+	return plan
+}
@@ -0,0 +1,69 @@
+package entitlements
+
+import "testing"
+
+func TestLoadDefault(t *testing.T) {
+	e, err := Load("")
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	free := e.Default()
+	if free.Name != "free" || free.MaxChirpLength != 140 {
+		t.Fatalf("unexpected default plan: %+v", free)
+	}
+	if free.Allows(FeatureEditChirps) {
+		t.Fatal("the free plan shouldn't allow editing")
+	}
+
+	red := e.Resolve("red", true)
+	if red.Name != "red" || !red.Allows(FeatureEditChirps) {
+		t.Fatalf("unexpected red plan: %+v", red)
+	}
+}
+
+func TestResolveFallsBack(t *testing.T) {
+	e, err := Parse([]byte(`{
+		"default_plan": "basic",
+		"plans": {
+			"basic": {"max_chirp_length": 100},
+			"pro": {"max_chirp_length": 500, "features": ["edit_chirps"]}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		plan   string
+		active bool
+		want   string
+	}{
+		{"active plan", "pro", true, "pro"},
+		{"lapsed plan", "pro", false, "basic"},
+		{"unknown plan", "gold", true, "basic"},
+		{"no plan", "", true, "basic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.Resolve(tt.plan, tt.active).Name; got != tt.want {
+				t.Errorf("Resolve(%q, %v) = %q, want %q", tt.plan, tt.active, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	for _, dat := range []string{
+		`{"plans": {"free": {"max_chirp_length": 140}}}`,
+		`{"default_plan": "free", "plans": {}}`,
+		`{"default_plan": "free", "plans": {"free": {"max_chirp_length": 0}}}`,
+		`{"default_plan": "free", "plans": {"free": {"max_chirp_length": 140, "chirps_per_hour": -1}}}`,
+		`not json`,
+	} {
+		if _, err := Parse([]byte(dat)); err == nil {
+			t.Errorf("Parse(%s) should have failed", dat)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Package oidc signs users in with an external OpenID Connect provider. It
+// covers the parts of a relying party Chirpy needs: discovery, the
+// authorization code flow with PKCE and ID token validation.
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultScopes ask for the claims needed to find or create the account.
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+type Config struct {
+	// Issuer is the provider's issuer URL, which discovery starts from and
+	// ID tokens must name exactly.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+}
+
+// Metadata is the part of the provider's discovery document Chirpy uses.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// IDToken holds the validated claims of an ID token.
+type IDToken struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	ExpiresAt     time.Time
+}
+
+// ErrInvalidIDToken wraps every reason an ID token is refused.
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+// jwksRefreshInterval keeps tokens with made-up key IDs from making us
+// fetch the provider's keys on every request.
+const jwksRefreshInterval = time.Minute
+
+// clockSkew is how far the provider's clock may be off from ours.
+const clockSkew = time.Minute
+
+// RelyingParty talks to one provider. Discovery and the provider's keys
+// are fetched on first use and cached, so a provider that is down at
+// startup doesn't keep Chirpy from starting.
+type RelyingParty struct {
+	config Config
+
+	mu            sync.Mutex
+	metadata      *Metadata
+	keys          map[string]any
+	keysFetchedAt time.Time
+}
+
+func New(config Config) *RelyingParty {
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	if len(config.Scopes) == 0 {
+		config.Scopes = DefaultScopes
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &RelyingParty{config: config}
+}
+
+// Discover fetches the provider's metadata from its well-known URL. The
+// issuer it names must be the one configured, or a provider could speak
+// for another.
+func (rp *RelyingParty) Discover(ctx context.Context) (Metadata, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	if rp.metadata != nil {
+		return *rp.metadata, nil
+	}
+
+	var metadata Metadata
+	err := rp.getJSON(ctx, rp.config.Issuer+"/.well-known/openid-configuration", &metadata)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("couldn't discover %s: %w", rp.config.Issuer, err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != rp.config.Issuer {
+		return Metadata{}, fmt.Errorf("provider at %s claims to be %q", rp.config.Issuer, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return Metadata{}, fmt.Errorf("discovery document of %s is missing endpoints", rp.config.Issuer)
+	}
+
+	rp.metadata = &metadata
+	return metadata, nil
+}
+
+// AuthCodeURL is where to send the user to sign in. codeChallenge is the
+// S256 challenge of a verifier kept for Exchange.
+func (rp *RelyingParty) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	metadata, err := rp.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", rp.config.ClientID)
+	query.Set("redirect_uri", rp.config.RedirectURL)
+	query.Set("scope", strings.Join(rp.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Exchange redeems an authorization code at the token endpoint and returns
+// the raw ID token, which still has to go through VerifyIDToken.
+func (rp *RelyingParty) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	metadata, err := rp.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {rp.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if rp.config.ClientSecret == "" {
+		form.Set("client_id", rp.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if rp.config.ClientSecret != "" {
+		// RFC 6749 section 2.3.1 form-encodes both halves first.
+		req.SetBasicAuth(url.QueryEscape(rp.config.ClientID), url.QueryEscape(rp.config.ClientSecret))
+	}
+
+	resp, err := rp.config.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body)
+	if err != nil {
+		return "", fmt.Errorf("couldn't read the token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return body.IDToken, nil
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string   `json:"nonce"`
+	AuthorizedParty string   `json:"azp"`
+	Email           string   `json:"email"`
+	EmailVerified   flexBool `json:"email_verified"`
+	Name            string   `json:"name"`
+}
+
+// VerifyIDToken checks an ID token as OpenID Connect Core section 3.1.3.7
+// asks: signed by one of the provider's keys, issued by it for us, not
+// expired, and carrying the nonce of this login.
+func (rp *RelyingParty) VerifyIDToken(ctx context.Context, raw, nonce string) (IDToken, error) {
+	metadata, err := rp.Discover(ctx)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	claims := idTokenClaims{}
+	_, err = jwt.ParseWithClaims(raw, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return rp.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(rp.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	if claims.Subject == "" {
+		return IDToken{}, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+	if (len(claims.Audience) > 1 || claims.AuthorizedParty != "") && claims.AuthorizedParty != rp.config.ClientID {
+		return IDToken{}, fmt.Errorf("%w: issued to %q", ErrInvalidIDToken, claims.AuthorizedParty)
+	}
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return IDToken{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	return IDToken{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+		ExpiresAt:     claims.ExpiresAt.Time,
+	}, nil
+}
+
+func (rp *RelyingParty) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := rp.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// flexBool accepts "true" and "false" as strings too, which some providers
+// send for email_verified.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "true", `"true"`:
+		*b = true
+	case "false", `"false"`, "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean %s", data)
+	}
+	return nil
+}
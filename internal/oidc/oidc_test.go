@@ -0,0 +1,199 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/oidc/oidctest"
+)
+
+const redirectURL = "http://localhost:8080/api/oidc/callback"
+
+func newStub(t *testing.T, secret string) (*oidctest.Provider, *RelyingParty) {
+	t.Helper()
+	idp, err := oidctest.New("chirpy", secret)
+	if err != nil {
+		t.Fatalf("couldn't start the stub provider: %v", err)
+	}
+	srv := httptest.NewServer(idp)
+	t.Cleanup(srv.Close)
+	idp.Issuer = srv.URL
+	idp.RedirectURL = redirectURL
+
+	rp := New(Config{
+		Issuer:       srv.URL,
+		ClientID:     "chirpy",
+		ClientSecret: secret,
+		RedirectURL:  redirectURL,
+	})
+	return idp, rp
+}
+
+// signIn runs the browser's part of the flow against the stub and returns
+// the code it redirects back with.
+func signIn(t *testing.T, rp *RelyingParty, state, nonce, verifier string) string {
+	t.Helper()
+	authURL, err := rp.AuthCodeURL(context.Background(), state, nonce, auth.CodeChallenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL returned error: %v", err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("couldn't reach the authorization endpoint: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorization endpoint returned %d", resp.StatusCode)
+	}
+
+	back, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+	if got := back.Query().Get("state"); got != state {
+		t.Fatalf("state = %q, want %q", got, state)
+	}
+	if back.Query().Get("code") == "" {
+		t.Fatalf("no code in redirect %s", back)
+	}
+	return back.Query().Get("code")
+}
+
+func TestCodeFlow(t *testing.T) {
+	for _, secret := range []string{"", "s3cret&="} {
+		idp, rp := newStub(t, secret)
+		idp.SetUser(oidctest.Identity{Subject: "42", Email: "staff@example.com", EmailVerified: true, Name: "Staff"})
+
+		verifier, _ := auth.MakeToken()
+		code := signIn(t, rp, "state", "nonce", verifier)
+
+		raw, err := rp.Exchange(context.Background(), code, verifier)
+		if err != nil {
+			t.Fatalf("Exchange returned error: %v", err)
+		}
+		token, err := rp.VerifyIDToken(context.Background(), raw, "nonce")
+		if err != nil {
+			t.Fatalf("VerifyIDToken returned error: %v", err)
+		}
+		if token.Subject != "42" || token.Email != "staff@example.com" || !token.EmailVerified || token.Issuer != idp.Issuer {
+			t.Fatalf("unexpected claims %+v", token)
+		}
+
+		_, err = rp.Exchange(context.Background(), code, verifier)
+		if err == nil {
+			t.Fatal("a code should only work once")
+		}
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	_, rp := newStub(t, "")
+
+	verifier, _ := auth.MakeToken()
+	code := signIn(t, rp, "state", "nonce", verifier)
+
+	other, _ := auth.MakeToken()
+	_, err := rp.Exchange(context.Background(), code, other)
+	if err == nil {
+		t.Fatal("expected the provider to refuse another verifier")
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	idp, rp := newStub(t, "")
+	user := oidctest.Identity{Subject: "42", Email: "staff@example.com", EmailVerified: true}
+	now := time.Now()
+
+	hs256, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.Claims(user, "nonce", now)).SignedString([]byte("secret"))
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, idp.Claims(user, "nonce", now)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+		raw    string
+	}{
+		{name: "wrong nonce", modify: func(c jwt.MapClaims) { c["nonce"] = "other" }},
+		{name: "no nonce", modify: func(c jwt.MapClaims) { delete(c, "nonce") }},
+		{name: "wrong audience", modify: func(c jwt.MapClaims) { c["aud"] = "someone-else" }},
+		{name: "foreign azp", modify: func(c jwt.MapClaims) { c["aud"] = []string{"chirpy", "someone-else"}; c["azp"] = "someone-else" }},
+		{name: "wrong issuer", modify: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "expired", modify: func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() }},
+		{name: "no expiry", modify: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "no subject", modify: func(c jwt.MapClaims) { delete(c, "sub") }},
+		{name: "HS256", raw: hs256},
+		{name: "unsigned", raw: unsigned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := tt.raw
+			if raw == "" {
+				claims := idp.Claims(user, "nonce", now)
+				tt.modify(claims)
+				var err error
+				raw, err = idp.SignIDToken(claims)
+				if err != nil {
+					t.Fatalf("couldn't sign: %v", err)
+				}
+			}
+			_, err := rp.VerifyIDToken(context.Background(), raw, "nonce")
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenKeyRotation(t *testing.T) {
+	idp, rp := newStub(t, "")
+	user := oidctest.Identity{Subject: "42"}
+
+	raw, _ := idp.SignIDToken(idp.Claims(user, "nonce", time.Now()))
+	_, err := rp.VerifyIDToken(context.Background(), raw, "nonce")
+	if err != nil {
+		t.Fatalf("VerifyIDToken returned error: %v", err)
+	}
+
+	// A new key is picked up once the refresh interval has passed.
+	idp.RotateKey()
+	raw, _ = idp.SignIDToken(idp.Claims(user, "nonce", time.Now()))
+	_, err = rp.VerifyIDToken(context.Background(), raw, "nonce")
+	if err == nil {
+		t.Fatal("expected the keys not to be refetched so soon")
+	}
+
+	rp.keysFetchedAt = time.Now().Add(-jwksRefreshInterval)
+	_, err = rp.VerifyIDToken(context.Background(), raw, "nonce")
+	if err != nil {
+		t.Fatalf("VerifyIDToken after rotation returned error: %v", err)
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	idp, _ := newStub(t, "")
+	rp := New(Config{Issuer: idp.Issuer, ClientID: "chirpy"})
+	idp.Issuer = "https://evil.example.com"
+
+	_, err := rp.Discover(context.Background())
+	if err == nil {
+		t.Fatal("expected discovery to refuse another issuer")
+	}
+}
+
+func TestFlexBool(t *testing.T) {
+	for input, want := range map[string]bool{`true`: true, `"true"`: true, `false`: false, `"false"`: false, `null`: false} {
+		var b flexBool
+		err := b.UnmarshalJSON([]byte(input))
+		if err != nil || bool(b) != want {
+			t.Fatalf("UnmarshalJSON(%s) = %v, %v, want %v", input, b, err, want)
+		}
+	}
+}
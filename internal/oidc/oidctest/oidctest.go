@@ -0,0 +1,259 @@
+// Package oidctest is a stand-in OpenID provider for tests and local
+// development. It signs in whoever is set as its User without asking, and
+// otherwise behaves like a strict provider: it checks the client, the
+// redirect URI and PKCE, and hands out each code once.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+)
+
+// Identity is the account the provider signs in.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type grant struct {
+	identity      Identity
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+type Provider struct {
+	// Issuer is the provider's own URL. Set it once the server is listening.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the only redirect URI accepted, if set.
+	RedirectURL string
+
+	mu     sync.Mutex
+	user   Identity
+	key    *rsa.PrivateKey
+	keyID  string
+	codes  map[string]grant
+	keyGen int
+}
+
+func New(clientID, clientSecret string) (*Provider, error) {
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		codes:        map[string]grant{},
+	}
+	err := p.RotateKey()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetUser picks who the next sign-in is for.
+func (p *Provider) SetUser(user Identity) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.user = user
+}
+
+// RotateKey replaces the signing key with a new one under a new key ID, and
+// stops publishing the old one.
+func (p *Provider) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keyGen++
+	p.key = key
+	p.keyID = fmt.Sprintf("stub-%d", p.keyGen)
+	return nil
+}
+
+// SignIDToken signs arbitrary claims with the current key, for tests that
+// need tokens a well-behaved provider wouldn't issue.
+func (p *Provider) SignIDToken(claims jwt.MapClaims) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = p.keyID
+	return token.SignedString(p.key)
+}
+
+// Claims are what the provider puts in the ID token for user.
+func (p *Provider) Claims(user Identity, nonce string, now time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            p.Issuer,
+		"sub":            user.Subject,
+		"aud":            p.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          nonce,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"name":           user.Name,
+	}
+}
+
+func (p *Provider) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case "/.well-known/openid-configuration":
+		p.serveDiscovery(w)
+	case "/jwks":
+		p.serveJWKS(w)
+	case "/authorize":
+		p.serveAuthorize(w, req)
+	case "/token":
+		p.serveToken(w, req)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (p *Provider) serveDiscovery(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *Provider) serveJWKS(w http.ResponseWriter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	public := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": p.keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *Provider) serveAuthorize(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	redirectURI := query.Get("redirect_uri")
+	if query.Get("client_id") != p.ClientID || redirectURI == "" || (p.RedirectURL != "" && redirectURI != p.RedirectURL) {
+		http.Error(w, "unknown client or redirect URI", http.StatusBadRequest)
+		return
+	}
+
+	back, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect URI", http.StatusBadRequest)
+		return
+	}
+	params := back.Query()
+	params.Set("state", query.Get("state"))
+
+	switch {
+	case query.Get("response_type") != "code":
+		params.Set("error", "unsupported_response_type")
+	case query.Get("code_challenge_method") != auth.CodeChallengeMethodS256 || !auth.ValidCodeChallenge(query.Get("code_challenge")):
+		params.Set("error", "invalid_request")
+	default:
+		code := randomString()
+		p.mu.Lock()
+		p.codes[code] = grant{
+			identity:      p.user,
+			redirectURI:   redirectURI,
+			nonce:         query.Get("nonce"),
+			codeChallenge: query.Get("code_challenge"),
+			expiresAt:     time.Now().Add(time.Minute),
+		}
+		p.mu.Unlock()
+		params.Set("code", code)
+	}
+
+	back.RawQuery = params.Encode()
+	http.Redirect(w, req, back.String(), http.StatusFound)
+}
+
+func (p *Provider) serveToken(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost || req.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, secret, basic := req.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = req.PostForm.Get("client_id")
+		secret = req.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || subtle.ConstantTimeCompare([]byte(secret), []byte(p.ClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if req.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	code := req.PostForm.Get("code")
+	p.mu.Lock()
+	g, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+	if !ok || time.Now().After(g.expiresAt) || req.PostForm.Get("redirect_uri") != g.redirectURI ||
+		auth.VerifyPKCE(req.PostForm.Get("code_verifier"), g.codeChallenge) != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := p.SignIDToken(p.Claims(g.identity, g.nonce, time.Now()))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,91 @@
+package polka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+)
+
+// Client delivers signed webhooks and retries failed deliveries with
+// exponential backoff, like Polka does.
+type Client struct {
+	WebhookURL  string
+	Secret      string
+	HTTPClient  *http.Client
+	MaxAttempts int
+	RetryDelay  time.Duration
+}
+
+func NewClient(webhookURL, secret string) *Client {
+	return &Client{
+		WebhookURL:  webhookURL,
+		Secret:      secret,
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 5,
+		RetryDelay:  500 * time.Millisecond,
+	}
+}
+
+// Delivery is the outcome of sending one event. StatusCode is zero when the
+// last attempt didn't get a response.
+type Delivery struct {
+	EventID    string
+	Attempts   int
+	StatusCode int
+}
+
+// Deliver sends event until it is acknowledged with a 2xx or MaxAttempts
+// is reached. Every attempt is signed afresh, so retries don't fall out of
+// the receiver's replay window.
+func (c *Client) Deliver(ctx context.Context, event Event) (Delivery, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	delivery := Delivery{EventID: event.ID}
+	delay := c.RetryDelay
+	var lastErr error
+	for delivery.Attempts < max(c.MaxAttempts, 1) {
+		if delivery.Attempts > 0 {
+			select {
+			case <-ctx.Done():
+				return delivery, ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+		delivery.Attempts++
+
+		delivery.StatusCode, lastErr = c.send(ctx, body)
+		if lastErr == nil && delivery.StatusCode >= 200 && delivery.StatusCode < 300 {
+			return delivery, nil
+		}
+	}
+
+	if lastErr != nil {
+		return delivery, fmt.Errorf("couldn't deliver %s: %w", event.ID, lastErr)
+	}
+	return delivery, fmt.Errorf("couldn't deliver %s: last attempt got %d", event.ID, delivery.StatusCode)
+}
+
+func (c *Client) send(ctx context.Context, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(auth.WebhookSignatureHeader, auth.SignWebhook(c.Secret, time.Now(), body))
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,103 @@
+// Package polka describes the webhooks Polka, our payment provider, sends
+// and can play them against a server the way Polka would.
+package polka
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventUserUpgraded        = "user.upgraded"
+	EventUserDowngraded      = "user.downgraded"
+	EventSubscriptionRenewed = "subscription.renewed"
+	EventPaymentFailed       = "payment.failed"
+)
+
+// Period is the length of one billing period.
+const Period = 30 * 24 * time.Hour
+
+type Event struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	// CreatedAt is when the event happened at Polka, which can be well
+	// before it is delivered. Receivers fall back to the time of receipt.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Data      EventData  `json:"data"`
+}
+
+type EventData struct {
+	UserID uuid.UUID `json:"user_id"`
+	// Plan and PeriodEnd are optional; receivers fall back to the default
+	// plan and a period starting on receipt.
+	Plan      string     `json:"plan,omitempty"`
+	PeriodEnd *time.Time `json:"period_end,omitempty"`
+}
+
+func NewEventID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "evt_" + hex.EncodeToString(b)
+}
+
+// Scenarios lists the event sequences Scenario can build.
+var Scenarios = map[string][]string{
+	"upgrade":   {EventUserUpgraded},
+	"renew":     {EventUserUpgraded, EventSubscriptionRenewed, EventSubscriptionRenewed},
+	"recover":   {EventUserUpgraded, EventPaymentFailed, EventSubscriptionRenewed},
+	"churn":     {EventUserUpgraded, EventPaymentFailed, EventUserDowngraded},
+	"lifecycle": {EventUserUpgraded, EventSubscriptionRenewed, EventPaymentFailed, EventSubscriptionRenewed, EventUserDowngraded},
+}
+
+func ScenarioNames() []string {
+	names := make([]string, 0, len(Scenarios))
+	for name := range Scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Scenario builds the events Polka would send for one customer, in the
+// order it would send them, a second apart from start. Upgrades and
+// renewals carry the end of the period they pay for, starting from start.
+func Scenario(name string, userID uuid.UUID, plan string, start time.Time) ([]Event, error) {
+	sequence, ok := Scenarios[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scenario %q", name)
+	}
+
+	periodEnd := start.UTC()
+	events := []Event{}
+	for i, eventType := range sequence {
+		createdAt := start.UTC().Add(time.Duration(i) * time.Second)
+		event := Event{
+			ID:        NewEventID(),
+			Event:     eventType,
+			CreatedAt: &createdAt,
+			Data:      EventData{UserID: userID},
+		}
+		switch eventType {
+		case EventUserUpgraded, EventSubscriptionRenewed:
+			periodEnd = periodEnd.Add(Period)
+			end := periodEnd
+			event.Data.Plan = plan
+			event.Data.PeriodEnd = &end
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Shuffle reorders events to mimic deliveries overtaking each other.
+func Shuffle(events []Event, rng *mathrand.Rand) {
+	rng.Shuffle(len(events), func(i, j int) {
+		events[i], events[j] = events[j], events[i]
+	})
+}
@@ -0,0 +1,97 @@
+package polka
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+)
+
+func TestScenario(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	userID := uuid.New()
+
+	events, err := Scenario("lifecycle", userID, "red", start)
+	if err != nil {
+		t.Fatalf("Scenario returned error: %v", err)
+	}
+	if len(events) != len(Scenarios["lifecycle"]) {
+		t.Fatalf("expected %d events, got %d", len(Scenarios["lifecycle"]), len(events))
+	}
+
+	seen := map[string]bool{}
+	var lastEnd, lastCreated time.Time
+	for _, event := range events {
+		if seen[event.ID] {
+			t.Fatalf("duplicate event ID %s", event.ID)
+		}
+		seen[event.ID] = true
+		if event.CreatedAt == nil || !event.CreatedAt.After(lastCreated) {
+			t.Fatalf("events should happen in order, got %v after %v", event.CreatedAt, lastCreated)
+		}
+		lastCreated = *event.CreatedAt
+		if event.Data.UserID != userID {
+			t.Fatalf("event for the wrong user: %+v", event)
+		}
+		if event.Data.PeriodEnd != nil {
+			if !event.Data.PeriodEnd.After(lastEnd) {
+				t.Fatalf("period ends should move forward, got %v after %v", event.Data.PeriodEnd, lastEnd)
+			}
+			lastEnd = *event.Data.PeriodEnd
+		}
+	}
+	if want := start.Add(3 * Period); !lastEnd.Equal(want) {
+		t.Fatalf("last period end = %v, want %v", lastEnd, want)
+	}
+
+	_, err = Scenario("nope", userID, "red", start)
+	if err == nil {
+		t.Fatal("expected an error for an unknown scenario")
+	}
+}
+
+func TestClientDeliverRetries(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(req.Body)
+		err := auth.VerifyWebhookSignature(req.Header, "secret", body, time.Minute, time.Now())
+		if err != nil {
+			t.Errorf("attempt %d: %v", attempts, err)
+		}
+		event := Event{}
+		json.Unmarshal(body, &event)
+		if event.ID != "evt_1" {
+			t.Errorf("unexpected event: %s", body)
+		}
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "secret")
+	c.RetryDelay = time.Millisecond
+	delivery, err := c.Deliver(context.Background(), Event{ID: "evt_1", Event: EventUserUpgraded})
+	if err != nil {
+		t.Fatalf("Deliver returned error: %v", err)
+	}
+	if delivery.Attempts != 3 || delivery.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected delivery: %+v", delivery)
+	}
+
+	c.MaxAttempts = 2
+	attempts = 0
+	_, err = c.Deliver(context.Background(), Event{ID: "evt_1", Event: EventUserUpgraded})
+	if err == nil {
+		t.Fatal("expected an error once attempts ran out")
+	}
+}
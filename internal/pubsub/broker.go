@@ -0,0 +1,173 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// Event is one message on the broker. IDs only ever increase; a bare Broker
+// numbers its events itself, while PostgresBus hands out IDs shared by
+// every instance.
+type Event struct {
+	ID   uint64
+	Type string
+	// Key routes the event, e.g. the author of a chirp, so subscribers can
+	// filter without decoding Data.
+	Key  string
+	Data json.RawMessage
+}
+
+// ErrHistoryGap means a subscriber asked to resume from an event that has
+// already fallen out of the replay buffer.
+var ErrHistoryGap = errors.New("requested events are no longer available")
+
+// Broker fans events out to in-process subscribers. Publishing never blocks:
+// a subscriber whose buffer is full is dropped and its channel closed, and
+// it is up to the subscriber to reconnect and resume from the last ID it saw.
+type Broker struct {
+	mu     sync.Mutex
+	lastID uint64
+	// floor is the ID after which no event is missing from history, either
+	// because it was evicted or because it happened before the broker
+	// started following the stream.
+	floor      uint64
+	history    []Event
+	maxHistory int
+	bufferSize int
+	subs       map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter func(Event) bool
+}
+
+func NewBroker(maxHistory, bufferSize int) *Broker {
+	return &Broker{
+		maxHistory: maxHistory,
+		bufferSize: bufferSize,
+		subs:       map[*Subscription]struct{}{},
+	}
+}
+
+// Publish encodes data and delivers it to every matching subscriber under
+// the next ID.
+func (b *Broker) Publish(eventType, key string, data any) (Event, error) {
+	dat, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := Event{
+		ID:   b.lastID + 1,
+		Type: eventType,
+		Key:  key,
+		Data: dat,
+	}
+	b.deliver(event)
+	return event, nil
+}
+
+// Deliver passes on an event numbered elsewhere. Events must arrive in ID
+// order; one at or below the last ID is a duplicate and is ignored.
+func (b *Broker) Deliver(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if event.ID <= b.lastID {
+		return
+	}
+	b.deliver(event)
+}
+
+// Restart forgets the history and drops every subscriber after events may
+// have been missed, e.g. while a connection was down. Subscribers reconnect
+// and only resume from after lastID, the newest event that can't have been
+// missed.
+func (b *Broker) Restart(lastID uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.history = nil
+	b.lastID = max(b.lastID, lastID)
+	b.floor = b.lastID
+	for sub := range b.subs {
+		b.drop(sub)
+	}
+}
+
+func (b *Broker) deliver(event Event) {
+	b.lastID = event.ID
+	b.history = append(b.history, event)
+	if len(b.history) > b.maxHistory {
+		evicted := len(b.history) - b.maxHistory
+		b.floor = b.history[evicted-1].ID
+		b.history = b.history[evicted:]
+	}
+
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber. When lastID is non-zero the events after
+// it are returned for replay; live events start right after them, with no
+// gap or overlap. filter may be nil to receive everything.
+func (b *Broker) Subscribe(lastID uint64, filter func(Event) bool) (*Subscription, []Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// An ID from the future was handed out by an earlier run of the
+	// process, or by an instance this one hasn't caught up with; either way
+	// there is no telling what came in between.
+	if lastID > b.lastID {
+		return nil, nil, ErrHistoryGap
+	}
+
+	replay := []Event{}
+	if lastID > 0 && lastID < b.lastID {
+		if lastID < b.floor {
+			return nil, nil, ErrHistoryGap
+		}
+		for _, event := range b.history {
+			if event.ID <= lastID {
+				continue
+			}
+			if filter != nil && !filter(event) {
+				continue
+			}
+			replay = append(replay, event)
+		}
+	}
+
+	ch := make(chan Event, b.bufferSize)
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+	b.subs[sub] = struct{}{}
+	return sub, replay, nil
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop(sub)
+}
+
+func (b *Broker) drop(sub *Subscription) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	close(sub.ch)
+}
@@ -0,0 +1,139 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBrokerDelivers(t *testing.T) {
+	b := NewBroker(10, 10)
+	sub, replay, err := b.Subscribe(0, func(e Event) bool { return e.Key == "alice" })
+	if err != nil {
+		t.Fatalf("Subscribe returned error: %v", err)
+	}
+	if len(replay) != 0 {
+		t.Fatalf("expected no replay for a fresh subscriber, got %d events", len(replay))
+	}
+
+	b.Publish("chirp.created", "bob", map[string]string{"body": "hi"})
+	want, err := b.Publish("chirp.created", "alice", map[string]string{"body": "hello"})
+	if err != nil {
+		t.Fatalf("Publish returned error: %v", err)
+	}
+
+	got := <-sub.C
+	if got.ID != want.ID || string(got.Data) != `{"body":"hello"}` {
+		t.Fatalf("unexpected event: %+v", got)
+	}
+	select {
+	case extra := <-sub.C:
+		t.Fatalf("filtered event was delivered: %+v", extra)
+	default:
+	}
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	b := NewBroker(10, 1)
+	sub, _, _ := b.Subscribe(0, nil)
+
+	b.Publish("chirp.created", "", 1)
+	b.Publish("chirp.created", "", 2)
+
+	if _, ok := <-sub.C; !ok {
+		t.Fatal("expected the buffered event before the channel closed")
+	}
+	if _, ok := <-sub.C; ok {
+		t.Fatal("expected the channel of a slow subscriber to be closed")
+	}
+
+	// Unsubscribing a dropped subscriber must not panic.
+	b.Unsubscribe(sub)
+}
+
+func TestBrokerResume(t *testing.T) {
+	b := NewBroker(2, 10)
+	first, _ := b.Publish("chirp.created", "", 1)
+	second, _ := b.Publish("chirp.created", "", 2)
+	third, _ := b.Publish("chirp.created", "", 3)
+
+	_, replay, err := b.Subscribe(second.ID, nil)
+	if err != nil {
+		t.Fatalf("Subscribe returned error: %v", err)
+	}
+	if len(replay) != 1 || replay[0].ID != third.ID {
+		t.Fatalf("unexpected replay: %+v", replay)
+	}
+
+	_, _, err = b.Subscribe(0, nil)
+	if err != nil {
+		t.Fatalf("lastID 0 should mean no resumption, got %v", err)
+	}
+
+	_, _, err = b.Subscribe(first.ID, nil)
+	if err != nil {
+		t.Fatalf("resuming right before the oldest kept event failed: %v", err)
+	}
+
+	b.Publish("chirp.created", "", 4)
+	_, _, err = b.Subscribe(first.ID, nil)
+	if !errors.Is(err, ErrHistoryGap) {
+		t.Fatalf("expected ErrHistoryGap, got %v", err)
+	}
+
+	// An ID the broker never handed out, e.g. from before a restart.
+	_, _, err = b.Subscribe(third.ID+10, nil)
+	if !errors.Is(err, ErrHistoryGap) {
+		t.Fatalf("expected ErrHistoryGap for an ID from the future, got %v", err)
+	}
+}
+
+func TestBrokerDeliverKeepsOutsideIDs(t *testing.T) {
+	b := NewBroker(10, 10)
+	b.Restart(41)
+	sub, _, _ := b.Subscribe(0, nil)
+
+	b.Deliver(Event{ID: 42, Type: "chirp.created", Data: []byte("1")})
+	// IDs from a shared sequence can skip numbers.
+	b.Deliver(Event{ID: 45, Type: "chirp.created", Data: []byte("2")})
+	// A duplicate, or one from before the broker caught up, is ignored.
+	b.Deliver(Event{ID: 45, Type: "chirp.created", Data: []byte("3")})
+	b.Deliver(Event{ID: 40, Type: "chirp.created", Data: []byte("4")})
+
+	for _, want := range []uint64{42, 45} {
+		got := <-sub.C
+		if got.ID != want {
+			t.Fatalf("got event %d, want %d", got.ID, want)
+		}
+	}
+	select {
+	case extra := <-sub.C:
+		t.Fatalf("ignored event was delivered: %+v", extra)
+	default:
+	}
+
+	_, replay, err := b.Subscribe(42, nil)
+	if err != nil || len(replay) != 1 || replay[0].ID != 45 {
+		t.Fatalf("resuming across a skipped ID: replay %+v, err %v", replay, err)
+	}
+	_, _, err = b.Subscribe(40, nil)
+	if !errors.Is(err, ErrHistoryGap) {
+		t.Fatalf("resuming from before the restart: expected ErrHistoryGap, got %v", err)
+	}
+}
+
+func TestBrokerRestartDropsSubscribers(t *testing.T) {
+	b := NewBroker(10, 10)
+	b.Publish("chirp.created", "", 1)
+	seen, _ := b.Publish("chirp.created", "", 2)
+	sub, _, _ := b.Subscribe(0, nil)
+
+	b.Restart(5)
+	if _, ok := <-sub.C; ok {
+		t.Fatal("expected subscribers to be dropped on a restart")
+	}
+
+	_, _, err := b.Subscribe(seen.ID, nil)
+	if !errors.Is(err, ErrHistoryGap) {
+		t.Fatalf("expected ErrHistoryGap for events from before the restart, got %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package pubsub
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Bus is what the server publishes and subscribes through. A bare Broker
+// only reaches the current process; PostgresBus extends it to every
+// instance sharing the database.
+type Bus interface {
+	Publish(eventType, key string, data any) (Event, error)
+	Subscribe(lastID uint64, filter func(Event) bool) (*Subscription, []Event, error)
+	Unsubscribe(sub *Subscription)
+}
+
+var (
+	_ Bus = (*Broker)(nil)
+	_ Bus = (*PostgresBus)(nil)
+)
+
+// Postgres caps NOTIFY payloads just under 8000 bytes.
+const maxNotifyPayload = 7900
+
+type notification struct {
+	ID   uint64          `json:"id"`
+	Type string          `json:"type"`
+	Key  string          `json:"key"`
+	Data json.RawMessage `json:"data"`
+}
+
+// PostgresBus relays events between instances with NOTIFY, numbering them
+// from the event_ids sequence. Every instance, the publishing one included,
+// feeds what it hears through LISTEN into its local Broker, so they all see
+// the same events under the same IDs in the same order, and a subscriber can
+// resume with an ID from any of them.
+type PostgresBus struct {
+	*Broker
+	db       *sql.DB
+	listener *pq.Listener
+	channel  string
+}
+
+func NewPostgresBus(broker *Broker, db *sql.DB, dbURL, channel string) (*PostgresBus, error) {
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("pubsub listener: %s", err)
+		}
+	})
+	err := listener.Listen(channel)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	b := &PostgresBus{
+		Broker:   broker,
+		db:       db,
+		listener: listener,
+		channel:  channel,
+	}
+	// Anything numbered before now may have been sent before we listened.
+	err = b.restart()
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// Publish numbers the event and sends it to every instance. It reaches
+// local subscribers the same way, once Postgres delivers it back.
+func (b *PostgresBus) Publish(eventType, key string, data any) (Event, error) {
+	dat, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Event{}, fmt.Errorf("couldn't relay %s event: %w", eventType, err)
+	}
+	defer tx.Rollback()
+
+	// Notifications are delivered in commit order, so holding the lock
+	// until the commit keeps that the same as ID order.
+	_, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", b.channel)
+	if err != nil {
+		return Event{}, fmt.Errorf("couldn't relay %s event: %w", eventType, err)
+	}
+	event := Event{Type: eventType, Key: key, Data: dat}
+	err = tx.QueryRowContext(ctx, "SELECT nextval('event_ids')").Scan(&event.ID)
+	if err != nil {
+		return Event{}, fmt.Errorf("couldn't relay %s event: %w", eventType, err)
+	}
+
+	payload, err := json.Marshal(notification{
+		ID:   event.ID,
+		Type: event.Type,
+		Key:  event.Key,
+		Data: event.Data,
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	if len(payload) > maxNotifyPayload {
+		return Event{}, fmt.Errorf("%s event is too large to relay (%d bytes)", eventType, len(payload))
+	}
+
+	_, err = tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", b.channel, string(payload))
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return Event{}, fmt.Errorf("couldn't relay %s event: %w", eventType, err)
+	}
+	return event, nil
+}
+
+// restart resets the local Broker to the newest ID handed out so far.
+func (b *PostgresBus) restart() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	lastID := uint64(0)
+	isCalled := false
+	err := b.db.QueryRowContext(ctx, "SELECT last_value, is_called FROM event_ids").Scan(&lastID, &isCalled)
+	if err != nil {
+		return fmt.Errorf("couldn't read the last event ID: %w", err)
+	}
+	if !isCalled {
+		lastID = 0
+	}
+	b.Broker.Restart(lastID)
+	return nil
+}
+
+// Run feeds notifications into the local Broker until ctx is cancelled.
+func (b *PostgresBus) Run(ctx context.Context) {
+	defer b.listener.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-b.listener.Notify:
+			// A nil notification means the connection was re-established;
+			// anything sent while it was down is lost, so subscribers have
+			// to start over from here.
+			if n == nil {
+				log.Println("pubsub listener reconnected, events may have been missed")
+				for err := b.restart(); err != nil; err = b.restart() {
+					log.Printf("pubsub: %s", err)
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(time.Second):
+					}
+				}
+				continue
+			}
+
+			msg := notification{}
+			err := json.Unmarshal([]byte(n.Extra), &msg)
+			if err != nil {
+				log.Printf("pubsub: couldn't decode notification: %s", err)
+				continue
+			}
+
+			b.Broker.Deliver(Event{
+				ID:   msg.ID,
+				Type: msg.Type,
+				Key:  msg.Key,
+				Data: msg.Data,
+			})
+		case <-time.After(90 * time.Second):
+			go b.listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	cases := []string{
+		"",
+		"hello",
+		"otpauth://totp/Chirpy:walt%40example.com?algorithm=SHA1&digits=6&issuer=Chirpy&period=30&secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP",
+		strings.Repeat("chirp ", 60),
+	}
+
+	for _, data := range cases {
+		c, err := Encode([]byte(data))
+		if err != nil {
+			t.Fatalf("Encode(%q) error = %v", data, err)
+		}
+		if c.Size != c.Version*4+17 || len(c.Modules) != c.Size {
+			t.Fatalf("version %d has size %d", c.Version, c.Size)
+		}
+		got := decode(t, c)
+		if got != data {
+			t.Errorf("decoded %q, want %q", got, data)
+		}
+	}
+}
+
+func TestEncodePicksSmallestVersion(t *testing.T) {
+	// Version 1-M holds 14 bytes.
+	c, _ := Encode(bytes.Repeat([]byte("a"), 14))
+	if c.Version != 1 {
+		t.Errorf("14 bytes used version %d, want 1", c.Version)
+	}
+	c, _ = Encode(bytes.Repeat([]byte("a"), 15))
+	if c.Version != 2 {
+		t.Errorf("15 bytes used version %d, want 2", c.Version)
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	_, err := Encode(make([]byte, 3000))
+	if err != ErrTooLong {
+		t.Errorf("error = %v, want ErrTooLong", err)
+	}
+}
+
+func TestFinderPatterns(t *testing.T) {
+	c, _ := Encode([]byte("hello"))
+	for _, corner := range [][2]int{{0, 0}, {c.Size - 7, 0}, {0, c.Size - 7}} {
+		for y := 0; y < 7; y++ {
+			for x := 0; x < 7; x++ {
+				dist := max(abs(x-3), abs(y-3))
+				want := dist != 2
+				if c.Modules[corner[1]+y][corner[0]+x] != want {
+					t.Fatalf("finder at %v wrong at (%d, %d)", corner, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestPNG(t *testing.T) {
+	c, _ := Encode([]byte("hello"))
+	data, err := c.PNG(4)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+
+	side := (c.Size + 2*quietZone) * 4
+	if img.Bounds().Dx() != side || img.Bounds().Dy() != side {
+		t.Fatalf("image is %v, want %dx%d", img.Bounds(), side, side)
+	}
+	isDark := func(x, y int) bool {
+		r, _, _, _ := img.At(x, y).RGBA()
+		return r == 0
+	}
+	if isDark(0, 0) {
+		t.Error("quiet zone isn't light")
+	}
+	if !isDark(quietZone*4, quietZone*4) {
+		t.Error("top-left finder corner isn't dark")
+	}
+}
+
+// decode reads a symbol back the way a scanner would once it has located
+// it: format bits, unmasking, codeword order, error correction check and
+// the byte-mode payload.
+func decode(t *testing.T, c *Code) string {
+	t.Helper()
+
+	format := 0
+	for i := 0; i <= 5; i++ {
+		format |= b2i(c.Modules[i][8]) << i
+	}
+	format |= b2i(c.Modules[7][8]) << 6
+	format |= b2i(c.Modules[8][8]) << 7
+	format |= b2i(c.Modules[8][7]) << 8
+	for i := 9; i < 15; i++ {
+		format |= b2i(c.Modules[8][14-i]) << i
+	}
+	format ^= 0x5412
+	if format>>13 != formatBitsM {
+		t.Fatalf("format info says level %d", format>>13)
+	}
+	mask := format >> 10 & 7
+
+	ref := newCode(c.Version)
+	ref.drawFunctionPatterns()
+	for y := range c.Modules {
+		for x := range c.Modules[y] {
+			if ref.isFunction[y][x] && ref.Modules[y][x] != c.Modules[y][x] && !isFormatModule(c.Size, x, y) {
+				t.Fatalf("function module (%d, %d) differs", x, y)
+			}
+			ref.Modules[y][x] = c.Modules[y][x]
+		}
+	}
+	ref.applyMask(mask)
+
+	raw := make([]byte, numRawDataModules(c.Version)/8)
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if ref.isFunction[y][x] || i >= len(raw)*8 {
+					continue
+				}
+				if ref.Modules[y][x] {
+					raw[i>>3] |= 1 << (7 - uint(i&7))
+				}
+				i++
+			}
+		}
+	}
+
+	numBlocks := numErrorCorrectionBlocks[c.Version]
+	eccLen := eccCodewordsPerBlock[c.Version]
+	numShort := numBlocks - len(raw)%numBlocks
+	shortLen := len(raw) / numBlocks
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for pos := 0; pos <= shortLen; pos++ {
+		for j := range blocks {
+			if pos == shortLen-eccLen && j < numShort {
+				continue
+			}
+			blocks[j] = append(blocks[j], raw[k])
+			k++
+		}
+	}
+
+	data := []byte{}
+	for _, block := range blocks {
+		for s := 0; s < eccLen; s++ {
+			if syndrome(block, s) != 0 {
+				t.Fatalf("block has a non-zero syndrome %d", s)
+			}
+		}
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+
+	bits := bitReader{data: data}
+	if mode := bits.read(4); mode != 0x4 {
+		t.Fatalf("mode = %#x, want byte mode", mode)
+	}
+	n := bits.read(charCountBits(c.Version))
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = byte(bits.read(8))
+	}
+	return string(out)
+}
+
+// syndrome evaluates the codeword polynomial at alpha^s; all of them are
+// zero for an undamaged block.
+func syndrome(block []byte, s int) byte {
+	x := byte(1)
+	for i := 0; i < s; i++ {
+		x = gfMultiply(x, 2)
+	}
+	result := byte(0)
+	for _, b := range block {
+		result = gfMultiply(result, x) ^ b
+	}
+	return result
+}
+
+func isFormatModule(size, x, y int) bool {
+	return (x == 8 && (y <= 8 || y >= size-8)) || (y == 8 && (x <= 8 || x >= size-8))
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		bit := r.data[r.pos>>3] >> (7 - uint(r.pos&7)) & 1
+		v = v<<1 | int(bit)
+		r.pos++
+	}
+	return v
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
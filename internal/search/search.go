@@ -0,0 +1,124 @@
+package search
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// StartMarker and StopMarker delimit matches in ts_headline output. They are
+// private-use runes that html.EscapeString leaves alone, so Highlight can
+// swap them for <mark> tags after escaping the text.
+const (
+	StartMarker = "\ue000"
+	StopMarker  = "\ue001"
+)
+
+var ErrEmptyQuery = errors.New("search query has no searchable terms")
+
+// ParseQuery turns a user's search string into Postgres to_tsquery syntax.
+// It supports "quoted phrases", prefix* terms, -negated terms and OR;
+// everything else is ANDed together. Punctuation is dropped, so the result is
+// always safe to hand to to_tsquery.
+func ParseQuery(q string) (string, error) {
+	terms := []string{}
+	or := false
+	for _, tok := range tokenize(q) {
+		if !tok.phrase && tok.text == "OR" {
+			or = len(terms) > 0
+			continue
+		}
+
+		term := tok.tsquery()
+		if term == "" {
+			continue
+		}
+		if len(terms) > 0 {
+			if or {
+				terms = append(terms, "|")
+			} else {
+				terms = append(terms, "&")
+			}
+		}
+		terms = append(terms, term)
+		or = false
+	}
+
+	if len(terms) == 0 {
+		return "", ErrEmptyQuery
+	}
+	return strings.Join(terms, " "), nil
+}
+
+// Highlight HTML-escapes a ts_headline fragment and wraps the matches in
+// <mark> tags.
+func Highlight(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, StartMarker, "<mark>")
+	return strings.ReplaceAll(escaped, StopMarker, "</mark>")
+}
+
+type token struct {
+	text   string
+	phrase bool
+}
+
+func tokenize(q string) []token {
+	tokens := []token{}
+	for {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		if q == "" {
+			return tokens
+		}
+
+		if q[0] == '"' {
+			end := strings.IndexByte(q[1:], '"')
+			if end < 0 {
+				tokens = append(tokens, token{text: q[1:], phrase: true})
+				return tokens
+			}
+			tokens = append(tokens, token{text: q[1 : end+1], phrase: true})
+			q = q[end+2:]
+			continue
+		}
+
+		end := strings.IndexFunc(q, unicode.IsSpace)
+		if end < 0 {
+			end = len(q)
+		}
+		tokens = append(tokens, token{text: q[:end]})
+		q = q[end:]
+	}
+}
+
+func (t token) tsquery() string {
+	text := t.text
+	negate, prefix := false, false
+	if !t.phrase {
+		negate = strings.HasPrefix(text, "-")
+		prefix = strings.HasSuffix(text, "*")
+	}
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+	for i, word := range words {
+		words[i] = strings.ToLower(word)
+	}
+	if prefix {
+		words[len(words)-1] += ":*"
+	}
+
+	term := strings.Join(words, " <-> ")
+	if len(words) > 1 {
+		term = "(" + term + ")"
+	}
+	if negate {
+		term = "!" + term
+	}
+	return term
+}
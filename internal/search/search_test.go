@@ -0,0 +1,87 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "single word",
+			query: "chirp",
+			want:  "chirp",
+		},
+		{
+			name:  "words are anded",
+			query: "hello   World",
+			want:  "hello & world",
+		},
+		{
+			name:  "phrase",
+			query: `"good morning" chirpy`,
+			want:  "(good <-> morning) & chirpy",
+		},
+		{
+			name:  "prefix",
+			query: "chir*",
+			want:  "chir:*",
+		},
+		{
+			name:  "negation and or",
+			query: "cats OR dogs -birds",
+			want:  "cats | dogs & !birds",
+		},
+		{
+			name:  "leading or is ignored",
+			query: "OR cats",
+			want:  "cats",
+		},
+		{
+			name:  "punctuation is dropped",
+			query: "it's & (evil) | !injection:*",
+			want:  "(it <-> s) & evil & injection:*",
+		},
+		{
+			name:  "unterminated phrase",
+			query: `"open phrase`,
+			want:  "(open <-> phrase)",
+		},
+		{
+			name:    "nothing searchable",
+			query:   `  "" - * `,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseQuery(tc.query)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error but got nil, query=%q", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("query mismatch: got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	headline := "<b>bold</b> " + StartMarker + "chirp" + StopMarker + " & more"
+	want := "&lt;b&gt;bold&lt;/b&gt; <mark>chirp</mark> &amp; more"
+	if got := Highlight(headline); got != want {
+		t.Fatalf("highlight mismatch: got %q want %q", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+// Package throttle decides how long to hold off after repeated failures,
+// such as wrong passwords. Counting the failures is left to the caller so
+// the counts can live wherever every instance can see them.
+package throttle
+
+import (
+	"math"
+	"time"
+)
+
+// Policy grants a few free failures, then doubles the wait after each one
+// until it reaches a lockout.
+type Policy struct {
+	// Free failures cost nothing, to forgive typos.
+	Free int
+	// Base is the wait after the first failure past the free ones.
+	Base time.Duration
+	// LockoutAfter failures in a row lock for the full Lockout, which also
+	// caps the backoff.
+	LockoutAfter int
+	Lockout      time.Duration
+	// Decay forgets the count once this long has passed since the last
+	// failure.
+	Decay time.Duration
+}
+
+// Delay is how long to refuse attempts after the given number of
+// consecutive failures.
+func (p Policy) Delay(failures int) time.Duration {
+	if failures <= p.Free {
+		return 0
+	}
+	if p.LockoutAfter > 0 && failures >= p.LockoutAfter {
+		return p.Lockout
+	}
+
+	doublings := failures - p.Free - 1
+	if doublings >= 62 {
+		return p.Lockout
+	}
+	delay := time.Duration(math.Min(float64(p.Base)*math.Pow(2, float64(doublings)), float64(math.MaxInt64)))
+	if p.Lockout > 0 && delay > p.Lockout {
+		return p.Lockout
+	}
+	return delay
+}
+
+// RetryAfter is the value of a Retry-After header for a lock lasting until
+// lockedUntil: whole seconds, rounded up so clients don't retry too early.
+func RetryAfter(lockedUntil, now time.Time) int {
+	wait := lockedUntil.Sub(now)
+	if wait <= 0 {
+		return 0
+	}
+	return int(math.Ceil(wait.Seconds()))
+}
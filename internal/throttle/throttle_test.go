@@ -0,0 +1,49 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	p := Policy{Free: 3, Base: time.Second, LockoutAfter: 10, Lockout: 15 * time.Minute}
+
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{9, 32 * time.Second},
+		{10, 15 * time.Minute},
+		{1000, 15 * time.Minute},
+	}
+	for _, tc := range cases {
+		if got := p.Delay(tc.failures); got != tc.want {
+			t.Errorf("Delay(%d) = %s, want %s", tc.failures, got, tc.want)
+		}
+	}
+}
+
+func TestDelayCappedByLockout(t *testing.T) {
+	p := Policy{Base: time.Minute, Lockout: 5 * time.Minute}
+	if got := p.Delay(4); got != 5*time.Minute {
+		t.Errorf("Delay(4) = %s, want the 5m cap", got)
+	}
+	if got := p.Delay(200); got != 5*time.Minute {
+		t.Errorf("Delay(200) = %s, want the 5m cap", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now()
+	if got := RetryAfter(now.Add(1500*time.Millisecond), now); got != 2 {
+		t.Errorf("RetryAfter(1.5s) = %d, want 2", got)
+	}
+	if got := RetryAfter(now.Add(-time.Second), now); got != 0 {
+		t.Errorf("RetryAfter(past) = %d, want 0", got)
+	}
+}
@@ -0,0 +1,100 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key from the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFC6238(t *testing.T) {
+	// The RFC lists eight digits; six-digit codes are their last six.
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tc := range cases {
+		got, err := Code(rfcSecret, Step(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code() error = %v", err)
+		}
+		if got != tc.want {
+			t.Errorf("Code at %d = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	step := Step(now)
+
+	prev, _ := Code(secret, step-1)
+	cur, _ := Code(secret, step)
+	stale, _ := Code(secret, step-2)
+
+	got, ok := Validate(secret, cur, now)
+	if !ok || got != step {
+		t.Errorf("current code: step %d, ok %t", got, ok)
+	}
+	got, ok = Validate(secret, prev, now)
+	if !ok || got != step-1 {
+		t.Errorf("previous code: step %d, ok %t", got, ok)
+	}
+	if _, ok := Validate(secret, stale, now); ok && stale != cur && stale != prev {
+		t.Error("a code two steps old was accepted")
+	}
+	if _, ok := Validate(secret, cur[:3]+" "+cur[3:], now); !ok {
+		t.Error("a code with a space wasn't accepted")
+	}
+	if _, ok := Validate(secret, "12345", now); ok {
+		t.Error("a short code was accepted")
+	}
+	if _, ok := Validate("not base32!", cur, now); ok {
+		t.Error("a malformed secret was accepted")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, _ := GenerateSecret()
+	b, _ := GenerateSecret()
+	if a == b {
+		t.Error("two secrets are equal")
+	}
+	if len(a) != 32 || strings.Contains(a, "=") {
+		t.Errorf("secret %q isn't 32 unpadded base32 characters", a)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Chirpy", "walt@example.com", "JBSWY3DPEHPK3PXP")
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("uri = %s", uri)
+	}
+	if u.Path != "/Chirpy:walt@example.com" {
+		t.Errorf("label = %q", u.Path)
+	}
+	q := u.Query()
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "Chirpy" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("query = %v", q)
+	}
+}
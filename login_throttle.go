@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/throttle"
+)
+
+var (
+	// accountThrottle protects one account, whether or not it exists, from
+	// password guessing spread over many addresses.
+	accountThrottle = throttle.Policy{
+		Free:         3,
+		Base:         time.Second,
+		LockoutAfter: 10,
+		Lockout:      15 * time.Minute,
+		Decay:        time.Hour,
+	}
+	// ipThrottle is looser, since many people can share an address, and
+	// stops one client from trying lots of accounts.
+	ipThrottle = throttle.Policy{
+		Free:         20,
+		Base:         time.Second,
+		LockoutAfter: 100,
+		Lockout:      time.Hour,
+		Decay:        time.Hour,
+	}
+)
+
+const loginThrottleSweepInterval = 10 * time.Minute
+
+type throttleKey struct {
+	key    string
+	policy throttle.Policy
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginThrottleKeys(email, ip string) []throttleKey {
+	return []throttleKey{
+		{key: accountThrottleKey(email), policy: accountThrottle},
+		{key: "ip:" + ip, policy: ipThrottle},
+	}
+}
+
+// clientIP is the address the request came from. X-Forwarded-For is only
+// believed behind a proxy we were told about, since anyone can send it.
+func (cfg *apiConfig) clientIP(req *http.Request) string {
+	if cfg.trustProxy {
+		forwarded := req.Header.Get("X-Forwarded-For")
+		if forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// loginLockedUntil is when the latest lock on any of keys ends, or the zero
+// time if none applies. It is checked before the password so a locked
+// account costs no hashing.
+func (cfg *apiConfig) loginLockedUntil(ctx context.Context, keys []throttleKey) (time.Time, error) {
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.key)
+	}
+
+	rows, err := cfg.db.GetLoginThrottles(ctx, names)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	lockedUntil := time.Time{}
+	for _, row := range rows {
+		if row.LockedUntil.After(time.Now()) && row.LockedUntil.After(lockedUntil) {
+			lockedUntil = row.LockedUntil
+		}
+	}
+	return lockedUntil, nil
+}
+
+// recordLoginFailure counts a failed attempt against every key and locks
+// those that went past their policy. It returns the latest lock.
+func (cfg *apiConfig) recordLoginFailure(ctx context.Context, keys []throttleKey) (time.Time, error) {
+	now := time.Now().UTC()
+	lockedUntil := time.Time{}
+	for _, k := range keys {
+		row, err := cfg.db.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+			Key:         k.key,
+			ResetBefore: now.Add(-k.policy.Decay),
+		})
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		delay := k.policy.Delay(int(row.Failures))
+		if delay == 0 {
+			continue
+		}
+		until := now.Add(delay)
+		err = cfg.db.LockLoginThrottle(ctx, database.LockLoginThrottleParams{
+			Key:         k.key,
+			LockedUntil: until,
+		})
+		if err != nil {
+			return time.Time{}, err
+		}
+		lockedUntil = later(lockedUntil, until)
+	}
+	return lockedUntil, nil
+}
+
+func setRetryAfter(w http.ResponseWriter, lockedUntil time.Time) {
+	seconds := throttle.RetryAfter(lockedUntil, time.Now())
+	if seconds > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+}
+
+// runLoginThrottleSweeper drops counters nobody has tripped in a while, so
+// the table doesn't keep every address that ever mistyped a password.
+func (cfg *apiConfig) runLoginThrottleSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	decay := max(accountThrottle.Decay, ipThrottle.Decay)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := cfg.db.DeleteStaleLoginThrottles(ctx, time.Now().UTC().Add(-decay))
+			if err != nil {
+				log.Printf("Couldn't delete stale login throttles: %s", err)
+			}
+		}
+	}
+}
@@ -1,24 +1,47 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	_ "github.com/lib/pq"
 
 	"log"
 	"net/http"
+	"net/url"
 	"sync/atomic"
 	"os"
+	"strings"
 
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
 	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/entitlements"
+	"github.com/rangaroo/chirpy-http-server/internal/mailer"
+	"github.com/rangaroo/chirpy-http-server/internal/oidc"
+	"github.com/rangaroo/chirpy-http-server/internal/pubsub"
 	"github.com/joho/godotenv"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
+	dbConn         *sql.DB
 	platform       string
-	tokenSecret    string
+	keyring        *auth.Keyring
+	events         pubsub.Bus
 	apiKey         string
+	entitlements   *entitlements.Entitlements
+	mailer         mailer.Mailer
+	publicURL      string
+	// unverifiedOK lets accounts chirp before confirming their email.
+	unverifiedOK   bool
+	// trustProxy takes the client address from X-Forwarded-For.
+	trustProxy     bool
+	revocations    *auth.RevocationList
+	// oidc is nil unless sign-in with an identity provider is configured.
+	oidc           *oidc.RelyingParty
+	// oidcReturnURL is where the browser goes after signing in with the
+	// identity provider, carrying a one-time code or an error.
+	oidcReturnURL  *url.URL
 }
 
 func main() {
@@ -36,51 +59,210 @@ func main() {
 		log.Fatal("PLATFORM must be set")
 	}
 
-	tokenSecret := os.Getenv("TOKEN_SECRET")
-	if tokenSecret == "" {
-		log.Fatal("TOKEN_SECRET must be set")
+	// The admin endpoints are guarded by roles now; see cmd/grantadmin.
+	if os.Getenv("ADMIN_API_KEY") != "" {
+		log.Println("ADMIN_API_KEY is no longer used and can be removed")
 	}
 
+	keyring, err := loadKeyring(os.Getenv("JWT_KEYS_DIR"), os.Getenv("JWT_ACTIVE_KID"))
+	if err != nil {
+		log.Fatalf("couldn't load the signing keys: %s", err)
+	}
+	// TOKEN_SECRET is only kept to verify HS256 tokens issued before the
+	// switch to asymmetric keys.
+	if tokenSecret := os.Getenv("TOKEN_SECRET"); tokenSecret != "" {
+		keyring.SetLegacySecret(tokenSecret)
+	}
+
+	// POLKA_KEY is the shared secret Polka signs its webhooks with.
 	apiKey := os.Getenv("POLKA_KEY") 
 	if apiKey == "" {
 		log.Fatal("POLKA_KEY must be set")
 	}
 
+	// Plans and their limits live in ENTITLEMENTS_FILE; the built-in plans
+	// are used when it isn't set.
+	plans, err := entitlements.Load(os.Getenv("ENTITLEMENTS_FILE"))
+	if err != nil {
+		log.Fatalf("couldn't load the plans: %s", err)
+	}
+
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "Chirpy <no-reply@chirpy.local>"
+	}
+	mail, err := mailer.New(os.Getenv("MAILER_URL"), mailFrom)
+	if err != nil {
+		log.Fatalf("couldn't set up the mailer: %s", err)
+	}
+
+	// PUBLIC_URL is where links in emails point to.
+	publicURL := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if publicURL == "" {
+		publicURL = "http://localhost:" + port
+	}
+
+	// Signing in with an OpenID provider is turned on by OIDC_ISSUER. The
+	// provider must redirect back to PUBLIC_URL/api/oidc/callback.
+	var relyingParty *oidc.RelyingParty
+	var oidcReturnURL *url.URL
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		clientID := os.Getenv("OIDC_CLIENT_ID")
+		if clientID == "" {
+			log.Fatal("OIDC_CLIENT_ID must be set when OIDC_ISSUER is")
+		}
+		relyingParty = oidc.New(oidc.Config{
+			Issuer:       issuer,
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  publicURL + "/api/oidc/callback",
+		})
+
+		returnURL := os.Getenv("OIDC_RETURN_URL")
+		if returnURL == "" {
+			returnURL = publicURL + "/app/"
+		}
+		oidcReturnURL, err = url.Parse(returnURL)
+		if err != nil || !oidcReturnURL.IsAbs() {
+			log.Fatalf("OIDC_RETURN_URL must be an absolute URL: %q", returnURL)
+		}
+	}
+
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatalf("could't open the database: %s", err)
 	}
 	dbQueries := database.New(db)
 
+	// Events go through Postgres so every instance behind the load balancer
+	// sees writes made by the others.
+	events, err := pubsub.NewPostgresBus(pubsub.NewBroker(1000, 64), db, dbURL, "chirpy_events")
+	if err != nil {
+		log.Fatalf("couldn't listen for events: %s", err)
+	}
+	go events.Run(context.Background())
+
+	// Revoked access tokens are refused on every instance, not just the one
+	// that revoked them.
+	revocations := auth.NewRevocationList()
+	keyring.SetRevocationChecker(revocations)
+
 	apiCfg := apiConfig {
 		fileserverHits: atomic.Int32{},
 		db:             dbQueries,
+		dbConn:         db,
 		platform:       platform,
+		keyring:        keyring,
+		events:         events,
 		apiKey:         apiKey,
+		entitlements:   plans,
+		mailer:         mail,
+		publicURL:      publicURL,
+		unverifiedOK:   os.Getenv("UNVERIFIED_CAN_CHIRP") == "true",
+		trustProxy:     os.Getenv("TRUST_PROXY") == "true",
+		revocations:    revocations,
+		oidc:           relyingParty,
+		oidcReturnURL:  oidcReturnURL,
+	}
+
+	go apiCfg.runHitsFlusher(context.Background(), hitsFlushInterval)
+	go apiCfg.runSubscriptionSweeper(context.Background(), subscriptionSweepInterval)
+	go apiCfg.runEmailOutbox(context.Background(), outboxInterval)
+	go apiCfg.runLoginThrottleSweeper(context.Background(), loginThrottleSweepInterval)
+	go apiCfg.runRevocationListener(context.Background())
+	go apiCfg.runRevocationSync(context.Background(), revocationSyncInterval)
+	go apiCfg.runOAuthCodeSweeper(context.Background(), oauthCodeSweepInterval)
+	if apiCfg.oidc != nil {
+		go apiCfg.runOIDCLoginSweeper(context.Background(), oidcLoginSweepInterval)
 	}
 
 	mux := http.NewServeMux()
 	mux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filePathRoot)))))
 
 	mux.HandleFunc("GET /api/healthz", handlerReadiness)
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)
+	mux.HandleFunc("GET /.well-known/oauth-authorization-server", apiCfg.handlerOAuthMetadata)
+	mux.HandleFunc("GET /admin/metrics", apiCfg.requirePermission(permMetricsRead, apiCfg.handlerMetrics))
 	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	mux.HandleFunc("GET /admin/webhooks", apiCfg.requirePermission(permWebhooksManage, apiCfg.handlerAdminWebhooksList))
+	mux.HandleFunc("POST /admin/webhooks/{webhookID}/replay", apiCfg.requirePermission(permWebhooksManage, apiCfg.handlerAdminWebhooksReplay))
+	mux.HandleFunc("POST /admin/users/{userID}/unlock", apiCfg.requirePermission(permUsersManage, apiCfg.handlerAdminUnlockUser))
+	mux.HandleFunc("POST /admin/users/{userID}/revoke-tokens", apiCfg.requirePermission(permUsersManage, apiCfg.handlerAdminRevokeUserTokens))
+	mux.HandleFunc("GET /admin/roles", apiCfg.requirePermission(permRolesManage, apiCfg.handlerAdminRolesList))
+	mux.HandleFunc("GET /admin/users/{userID}/roles", apiCfg.requirePermission(permRolesManage, apiCfg.handlerAdminUserRolesList))
+	mux.HandleFunc("PUT /admin/users/{userID}/roles/{role}", apiCfg.requirePermission(permRolesManage, apiCfg.handlerAdminUserRoleGrant))
+	mux.HandleFunc("DELETE /admin/users/{userID}/roles/{role}", apiCfg.requirePermission(permRolesManage, apiCfg.handlerAdminUserRoleRevoke))
 
 	mux.HandleFunc("POST /api/users", apiCfg.handlerUsersCreate)
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUsersUpdate)
+	mux.HandleFunc("PUT /api/users", apiCfg.requireLogin(apiCfg.handlerUsersUpdate))
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.requireAuth(auth.ScopeProfileWrite, apiCfg.handlerFollow))
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.requireAuth(auth.ScopeProfileWrite, apiCfg.handlerUnfollow))
+	mux.HandleFunc("GET /api/users/{userID}/followers", apiCfg.handlerFollowersList)
+	mux.HandleFunc("GET /api/users/{userID}/following", apiCfg.handlerFollowingList)
 
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerChirpsCreate)
+	mux.HandleFunc("GET /api/subscription", apiCfg.requireAuth(auth.ScopeProfileRead, apiCfg.handlerSubscriptionGet))
+
+	mux.HandleFunc("GET /api/timeline", apiCfg.requireAuth(auth.ScopeChirpsRead, apiCfg.handlerTimeline))
+
+	mux.HandleFunc("POST /api/chirps", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handlerChirpsCreate))
 	mux.HandleFunc("GET /api/chirps", apiCfg.handlerChirpsRetrieve)
+	mux.HandleFunc("GET /api/chirps/search", apiCfg.handlerChirpsSearch)
+	mux.HandleFunc("GET /api/chirps/stream", apiCfg.handlerChirpsStream)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerChirpsGet)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerChirpsDelete)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handlerChirpsUpdate))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handlerChirpsDelete))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.handlerChirpsRevisions)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", apiCfg.handlerChirpsThread)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handlerChirpsLike))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handlerChirpsUnlike))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/likes", apiCfg.handlerChirpsLikesList)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handlerChirpsRechirp))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", apiCfg.requireAuth(auth.ScopeChirpsWrite, apiCfg.handlerChirpsUnrechirp))
 
 	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
+	mux.HandleFunc("POST /api/login/2fa", apiCfg.handlerLoginTwoFactor)
+	if apiCfg.oidc != nil {
+		mux.HandleFunc("GET /api/oidc/login", apiCfg.handlerOIDCLogin)
+		mux.HandleFunc("GET /api/oidc/callback", apiCfg.handlerOIDCCallback)
+		mux.HandleFunc("POST /api/oidc/token", apiCfg.handlerOIDCToken)
+	}
+	mux.HandleFunc("POST /api/2fa/totp/enroll", apiCfg.requireLogin(apiCfg.handlerTOTPEnroll))
+	mux.HandleFunc("POST /api/2fa/totp/confirm", apiCfg.requireLogin(apiCfg.handlerTOTPConfirm))
+	mux.HandleFunc("POST /api/2fa/recovery-codes", apiCfg.requireLogin(apiCfg.handlerRecoveryCodesRegenerate))
+	mux.HandleFunc("POST /api/2fa/disable", apiCfg.requireLogin(apiCfg.handlerTwoFactorDisable))
+	mux.HandleFunc("POST /api/email-verification/confirm", apiCfg.handlerEmailVerificationConfirm)
+	mux.HandleFunc("GET /app/verify-email", apiCfg.handlerVerifyEmailPage)
+	mux.HandleFunc("POST /app/verify-email", apiCfg.handlerVerifyEmailForm)
+	mux.HandleFunc("POST /api/email-verification/resend", apiCfg.requireAuth(auth.ScopeProfileWrite, apiCfg.handlerEmailVerificationResend))
+	mux.HandleFunc("POST /api/password-reset/request", apiCfg.handlerPasswordResetRequest)
+	mux.HandleFunc("POST /api/password-reset/confirm", apiCfg.handlerPasswordResetConfirm)
+	mux.HandleFunc("GET /app/reset-password", apiCfg.handlerPasswordResetPage)
+	mux.HandleFunc("POST /app/reset-password", apiCfg.handlerPasswordResetForm)
 
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
 
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
+	mux.HandleFunc("POST /api/logout", apiCfg.requireLogin(apiCfg.handlerLogout))
+
+	mux.HandleFunc("POST /api/tokens", apiCfg.requireLogin(apiCfg.handlerTokensCreate))
+	mux.HandleFunc("GET /api/tokens", apiCfg.requireLogin(apiCfg.handlerTokensList))
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", apiCfg.requireLogin(apiCfg.handlerTokensRevoke))
+
+	mux.HandleFunc("POST /api/oauth/clients", apiCfg.requireLogin(apiCfg.handlerOAuthClientsCreate))
+	mux.HandleFunc("GET /api/oauth/clients", apiCfg.requireLogin(apiCfg.handlerOAuthClientsList))
+	mux.HandleFunc("DELETE /api/oauth/clients/{clientID}", apiCfg.requireLogin(apiCfg.handlerOAuthClientsDelete))
+
+	mux.HandleFunc("GET /oauth/authorize", apiCfg.handlerOAuthAuthorize)
+	mux.HandleFunc("POST /oauth/authorize", apiCfg.handlerOAuthAuthorizeDecision)
+	mux.HandleFunc("POST /oauth/token", apiCfg.handlerOAuthToken)
+	mux.HandleFunc("POST /oauth/revoke", apiCfg.handlerOAuthRevoke)
+	mux.HandleFunc("POST /oauth/introspect", apiCfg.handlerOAuthIntrospect)
+
+	mux.HandleFunc("GET /api/sessions", apiCfg.requireLogin(apiCfg.handlerSessionsList))
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", apiCfg.requireLogin(apiCfg.handlerSessionsRevoke))
+	mux.HandleFunc("POST /api/sessions/revoke-all", apiCfg.requireLogin(apiCfg.handlerSessionsRevokeAll))
 
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerUsersUpgrade)
+	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPolkaWebhooks)
 
 	server := &http.Server{
 		Addr:     ":" + port,
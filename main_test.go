@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/pubsub"
+)
+
+// newTestConfig connects to the database in TEST_DB_URL, which must be
+// migrated. Tests that need one are skipped without it.
+func newTestConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("couldn't open the database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	key, err := auth.GenerateSigningKey("test")
+	if err != nil {
+		t.Fatalf("couldn't generate a signing key: %v", err)
+	}
+
+	return &apiConfig{
+		db:          database.New(db),
+		dbConn:      db,
+		platform:    "dev",
+		keyring:     auth.NewKeyring(key),
+		events:      pubsub.NewBroker(100, 16),
+		publicURL:   "http://localhost:8080",
+		revocations: auth.NewRevocationList(),
+	}
+}
+
+// createTestUser signs up a throwaway account with a confirmed email and
+// removes it, with everything it owns, when the test ends.
+func createTestUser(t *testing.T, cfg *apiConfig) database.User {
+	t.Helper()
+	email := uuid.NewString() + "@example.com"
+	user, err := cfg.db.CreateUser(context.Background(), database.CreateUserParams{
+		Email:          email,
+		HashedPassword: "unused",
+	})
+	if err != nil {
+		t.Fatalf("couldn't create a user: %v", err)
+	}
+	t.Cleanup(func() {
+		cfg.dbConn.Exec("DELETE FROM users WHERE id = $1", user.ID)
+	})
+
+	user, err = cfg.db.ConfirmUserEmail(context.Background(), database.ConfirmUserEmailParams{
+		ID:    user.ID,
+		Email: email,
+	})
+	if err != nil {
+		t.Fatalf("couldn't confirm the email: %v", err)
+	}
+	return user
+}
+
+// loginAs sets the Authorization header of a login session for user.
+func loginAs(t *testing.T, cfg *apiConfig, req *http.Request, user database.User) {
+	t.Helper()
+	token, err := cfg.makeAccessToken(req.Context(), user)
+	if err != nil {
+		t.Fatalf("couldn't make an access token: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+}
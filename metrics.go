@@ -1,11 +1,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 )
 
+// hitsFlushInterval is how often each instance adds the hits it counted to
+// the shared total, and so how far behind the total can be.
+const hitsFlushInterval = 5 * time.Second
+
 func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, req *http.Request) {
+	hits, err := cfg.db.GetFileserverHits(req.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get the hits", err)
+		return
+	}
+	hits += int64(cfg.fileserverHits.Load())
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(fmt.Sprintf(`
@@ -15,7 +29,7 @@ func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, req *http.Request) {
     		<p>Chirpy has been visited %d times!</p>
   		</body>
 	</html>
-	`, cfg.fileserverHits.Load())))
+	`, hits)))
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -26,3 +40,29 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(h)
 }
+
+// runHitsFlusher moves the hits counted here into the total shared by every
+// instance until ctx is cancelled. Hits that couldn't be saved are kept for
+// the next try.
+func (cfg *apiConfig) runHitsFlusher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		hits := cfg.fileserverHits.Swap(0)
+		if hits == 0 {
+			continue
+		}
+		err := cfg.db.AddFileserverHits(ctx, int64(hits))
+		if err != nil {
+			cfg.fileserverHits.Add(hits)
+			log.Printf("Couldn't save the fileserver hits: %s", err)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/totp"
+)
+
+// oauthCodeTTL is how long a client has to redeem an authorization code.
+const oauthCodeTTL = time.Minute
+
+var scopeDescriptions = map[string]string{
+	auth.ScopeChirpsRead:   "Read your timeline",
+	auth.ScopeChirpsWrite:  "Post, edit and delete chirps, likes and rechirps as you",
+	auth.ScopeProfileRead:  "See your subscription",
+	auth.ScopeProfileWrite: "Change who you follow",
+}
+
+// authorizationRequest is a validated request from a client to act for the
+// user, as carried through the consent page.
+type authorizationRequest struct {
+	client        database.OauthClient
+	redirectURI   string
+	scopes        []string
+	state         string
+	codeChallenge string
+}
+
+// oauthError is an error response defined by RFC 6749.
+type oauthError struct {
+	code        string
+	description string
+}
+
+func (e *oauthError) Error() string {
+	return e.code + ": " + e.description
+}
+
+// readAuthorizationRequest checks the parameters of /oauth/authorize. Until
+// the client and redirect URI are known to be good, the error can't be sent
+// back to the client and the returned request has no redirectURI.
+func (cfg *apiConfig) readAuthorizationRequest(req *http.Request, form url.Values) (authorizationRequest, error) {
+	ar := authorizationRequest{state: form.Get("state")}
+
+	clientID, err := uuid.Parse(form.Get("client_id"))
+	if err != nil {
+		return ar, &oauthError{"invalid_request", "Unknown client"}
+	}
+	client, err := cfg.db.GetOAuthClient(req.Context(), clientID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && client.RevokedAt.Valid) {
+		return ar, &oauthError{"invalid_request", "Unknown client"}
+	}
+	if err != nil {
+		return ar, err
+	}
+	ar.client = client
+
+	redirectURI := form.Get("redirect_uri")
+	if redirectURI == "" && len(client.RedirectUris) == 1 {
+		redirectURI = client.RedirectUris[0]
+	}
+	if !slices.Contains(client.RedirectUris, redirectURI) {
+		return ar, &oauthError{"invalid_request", "The redirect URI isn't registered for this client"}
+	}
+	ar.redirectURI = redirectURI
+
+	if form.Get("response_type") != "code" {
+		return ar, &oauthError{"unsupported_response_type", "Only the code response type is supported"}
+	}
+
+	ar.scopes = auth.ParseScope(form.Get("scope"))
+	if len(ar.scopes) == 0 {
+		ar.scopes = client.Scopes
+	}
+	for _, scope := range ar.scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return ar, &oauthError{"invalid_scope", "The client may not ask for " + scope}
+		}
+	}
+
+	if form.Get("code_challenge_method") != auth.CodeChallengeMethodS256 {
+		return ar, &oauthError{"invalid_request", "PKCE with code_challenge_method=S256 is required"}
+	}
+	ar.codeChallenge = form.Get("code_challenge")
+	if !auth.ValidCodeChallenge(ar.codeChallenge) {
+		return ar, &oauthError{"invalid_request", "Invalid code_challenge"}
+	}
+
+	return ar, nil
+}
+
+// redirect sends the user back to the client with the given parameters and
+// the state the client passed in.
+func (ar authorizationRequest) redirect(w http.ResponseWriter, req *http.Request, params url.Values) {
+	u, _ := url.Parse(ar.redirectURI)
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if ar.state != "" {
+		query.Set("state", ar.state)
+	}
+	u.RawQuery = query.Encode()
+	http.Redirect(w, req, u.String(), http.StatusFound)
+}
+
+// respondToAuthorizationError redirects an error back to the client when
+// that is safe, and shows it to the user otherwise so an attacker can't use
+// Chirpy to redirect people anywhere.
+func (cfg *apiConfig) respondToAuthorizationError(w http.ResponseWriter, req *http.Request, ar authorizationRequest, err error) {
+	var oauthErr *oauthError
+	if !errors.As(err, &oauthErr) {
+		log.Printf("Couldn't handle the authorization request: %s", err)
+		cfg.renderConsent(w, http.StatusInternalServerError, consentPage{Error: "Something went wrong, try again later."})
+		return
+	}
+	if ar.redirectURI == "" {
+		cfg.renderConsent(w, http.StatusBadRequest, consentPage{Error: oauthErr.description})
+		return
+	}
+	ar.redirect(w, req, url.Values{
+		"error":             {oauthErr.code},
+		"error_description": {oauthErr.description},
+	})
+}
+
+// handlerOAuthAuthorize shows the consent page, where the user signs in and
+// decides whether the client may act for them.
+func (cfg *apiConfig) handlerOAuthAuthorize(w http.ResponseWriter, req *http.Request) {
+	ar, err := cfg.readAuthorizationRequest(req, req.URL.Query())
+	if err != nil {
+		cfg.respondToAuthorizationError(w, req, ar, err)
+		return
+	}
+
+	cfg.renderConsent(w, http.StatusOK, newConsentPage(ar, req.URL.Query(), ""))
+}
+
+// handlerOAuthAuthorizeDecision takes the consent form. Approving needs the
+// user's password, and their second factor if they have one, and is
+// throttled like a login.
+func (cfg *apiConfig) handlerOAuthAuthorizeDecision(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		cfg.renderConsent(w, http.StatusBadRequest, consentPage{Error: "Couldn't read the form."})
+		return
+	}
+	form := req.PostForm
+
+	ar, err := cfg.readAuthorizationRequest(req, form)
+	if err != nil {
+		cfg.respondToAuthorizationError(w, req, ar, err)
+		return
+	}
+
+	if form.Get("decision") != "approve" {
+		cfg.respondToAuthorizationError(w, req, ar, &oauthError{"access_denied", "The user declined"})
+		return
+	}
+
+	email := form.Get("email")
+	page := newConsentPage(ar, form, email)
+
+	throttleKeys := loginThrottleKeys(email, cfg.clientIP(req))
+	lockedUntil, err := cfg.loginLockedUntil(req.Context(), throttleKeys)
+	if err != nil {
+		cfg.respondToAuthorizationError(w, req, ar, err)
+		return
+	}
+	if !lockedUntil.IsZero() {
+		setRetryAfter(w, lockedUntil)
+		page.Error = "Too many failed attempts, try again later."
+		cfg.renderConsent(w, http.StatusTooManyRequests, page)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		cfg.respondToAuthorizationError(w, req, ar, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	user, err := cfg.checkConsentCredentials(req, qtx, email, form.Get("password"), form.Get("code"))
+	if errors.Is(err, errSecondFactorRequired) {
+		page.Error = "Enter your password again with the code from your authenticator app, or a recovery code."
+		page.NeedsCode = true
+		cfg.renderConsent(w, http.StatusUnauthorized, page)
+		return
+	}
+	if errors.Is(err, errIncorrectPassword) || errors.Is(err, errSecondFactorInvalid) {
+		lockedUntil, err := cfg.recordLoginFailure(req.Context(), throttleKeys)
+		if err != nil {
+			cfg.respondToAuthorizationError(w, req, ar, err)
+			return
+		}
+		setRetryAfter(w, lockedUntil)
+		page.Error = "Incorrect email or password."
+		if user.ID != uuid.Nil {
+			page.Error = "Incorrect code. Enter your password again with a current code."
+			page.NeedsCode = true
+		}
+		cfg.renderConsent(w, http.StatusUnauthorized, page)
+		return
+	}
+	if err != nil {
+		cfg.respondToAuthorizationError(w, req, ar, err)
+		return
+	}
+
+	code, err := auth.MakeToken()
+	if err != nil {
+		cfg.respondToAuthorizationError(w, req, ar, err)
+		return
+	}
+	err = qtx.CreateOAuthAuthorizationCode(req.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      ar.client.ID,
+		UserID:        user.ID,
+		RedirectUri:   ar.redirectURI,
+		Scopes:        ar.scopes,
+		CodeChallenge: ar.codeChallenge,
+		ExpiresAt:     time.Now().UTC().Add(oauthCodeTTL),
+	})
+	if err != nil {
+		cfg.respondToAuthorizationError(w, req, ar, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		cfg.respondToAuthorizationError(w, req, ar, err)
+		return
+	}
+
+	err = cfg.db.ClearLoginThrottle(req.Context(), accountThrottleKey(email))
+	if err != nil {
+		log.Printf("Couldn't clear the login throttle after an authorization: %s", err)
+	}
+
+	ar.redirect(w, req, url.Values{"code": {code}})
+}
+
+var (
+	errIncorrectPassword    = errors.New("incorrect email or password")
+	errSecondFactorRequired = errors.New("second factor required")
+)
+
+// checkConsentCredentials signs the user in on the consent page. When the
+// password is right but the second factor is missing or wrong, the user is
+// returned along with the error so the page can ask for it.
+func (cfg *apiConfig) checkConsentCredentials(req *http.Request, q *database.Queries, email, password, code string) (database.User, error) {
+	user, err := q.GetUserByEmail(req.Context(), email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, errIncorrectPassword
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	match, err := auth.CheckPasswordHash(password, user.HashedPassword)
+	if err != nil {
+		return database.User{}, err
+	}
+	if !match {
+		return database.User{}, errIncorrectPassword
+	}
+
+	enabled, err := cfg.hasTwoFactor(req.Context(), user.ID)
+	if err != nil {
+		return database.User{}, err
+	}
+	if !enabled {
+		return user, nil
+	}
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return user, errSecondFactorRequired
+	}
+	recoveryCode := ""
+	if len(code) != totp.Digits {
+		code, recoveryCode = "", code
+	}
+	err = checkSecondFactor(req.Context(), q, user.ID, code, recoveryCode)
+	if err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+type consentScope struct {
+	Name        string
+	Description string
+}
+
+type consentPage struct {
+	ClientName string
+	Scopes     []consentScope
+	Params     map[string]string
+	Email      string
+	NeedsCode  bool
+	Error      string
+}
+
+// newConsentPage carries the authorization parameters through the form, so
+// the decision is checked against exactly what the user was shown.
+func newConsentPage(ar authorizationRequest, form url.Values, email string) consentPage {
+	page := consentPage{
+		ClientName: ar.client.Name,
+		Params:     map[string]string{},
+		Email:      email,
+	}
+	for _, scope := range ar.scopes {
+		page.Scopes = append(page.Scopes, consentScope{Name: scope, Description: scopeDescriptions[scope]})
+	}
+	for _, key := range []string{"response_type", "client_id", "redirect_uri", "state", "code_challenge", "code_challenge_method"} {
+		if form.Has(key) {
+			page.Params[key] = form.Get(key)
+		}
+	}
+	page.Params["scope"] = auth.FormatScope(ar.scopes)
+	return page
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Authorize {{if .ClientName}}{{.ClientName}}{{else}}app{{end}} - Chirpy</title>
+  <style>
+    body { font-family: sans-serif; max-width: 28rem; margin: 3rem auto; padding: 0 1rem; }
+    label { display: block; margin-top: 0.75rem; }
+    input { width: 100%; padding: 0.4rem; box-sizing: border-box; }
+    .error { color: #b00020; }
+    .buttons { margin-top: 1.5rem; display: flex; gap: 0.5rem; }
+  </style>
+</head>
+<body>
+{{if .ClientName}}
+  <h1>Authorize {{.ClientName}}</h1>
+  <p><strong>{{.ClientName}}</strong> wants to use your Chirpy account to:</p>
+  <ul>
+  {{range .Scopes}}<li>{{.Description}} <code>{{.Name}}</code></li>
+  {{end}}</ul>
+  {{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+  <form method="post" action="/oauth/authorize">
+    {{range $key, $value := .Params}}<input type="hidden" name="{{$key}}" value="{{$value}}">
+    {{end}}
+    <label>Email <input type="email" name="email" value="{{.Email}}" autocomplete="username" required></label>
+    <label>Password <input type="password" name="password" autocomplete="current-password"></label>
+    {{if .NeedsCode}}<label>Authentication or recovery code <input name="code" autocomplete="one-time-code" autofocus></label>{{end}}
+    <div class="buttons">
+      <button type="submit" name="decision" value="approve">Allow</button>
+      <button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+    </div>
+  </form>
+{{else}}
+  <h1>Can't authorize this app</h1>
+  <p class="error">{{.Error}}</p>
+{{end}}
+</body>
+</html>
+`))
+
+// renderConsent shows the consent page.
+func (cfg *apiConfig) renderConsent(w http.ResponseWriter, status int, page consentPage) {
+	renderPage(w, status, consentTemplate, page)
+}
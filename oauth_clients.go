@@ -0,0 +1,209 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+const (
+	maxClientNameLength   = 100
+	maxClientRedirectURIs = 10
+)
+
+// OAuthClient is a third-party app registered to act for Chirpy users. Its
+// secret is only shown when it is registered.
+type OAuthClient struct {
+	ID           uuid.UUID `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func databaseClientToClient(client database.OauthClient) OAuthClient {
+	return OAuthClient{
+		ID:           client.ID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		Confidential: client.SecretHash.Valid,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// validRedirectURI accepts absolute https URIs without a fragment. Plain
+// http is only allowed back to the loopback address, for native apps and
+// local development.
+func validRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Fragment != "" || u.Host == "" {
+		return fmt.Errorf("%q must be absolute and have no fragment", raw)
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" || net.ParseIP(host).IsLoopback() {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q must use https, or http to the loopback address", raw)
+}
+
+// handlerOAuthClientsCreate registers an app owned by the caller. Apps that
+// can keep a secret, like a web backend, should ask to be confidential;
+// public clients rely on PKCE alone.
+func (cfg *apiConfig) handlerOAuthClientsCreate(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+	type returnVals struct {
+		OAuthClient
+		ClientSecret string `json:"client_secret,omitempty"`
+	}
+
+	userID := callerOf(req).UserID
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	if params.Name == "" || len(params.Name) > maxClientNameLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Name must be 1 to %d characters", maxClientNameLength), nil)
+		return
+	}
+	if len(params.RedirectURIs) == 0 || len(params.RedirectURIs) > maxClientRedirectURIs {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Register 1 to %d redirect URIs", maxClientRedirectURIs), nil)
+		return
+	}
+	for _, uri := range params.RedirectURIs {
+		err = validRedirectURI(uri)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid redirect URI", err)
+			return
+		}
+	}
+	if len(params.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one scope is required", nil)
+		return
+	}
+	for _, scope := range params.Scopes {
+		if !auth.ValidScope(scope) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown scope %q", scope), nil)
+			return
+		}
+	}
+
+	secret := ""
+	secretHash := sql.NullString{}
+	if params.Confidential {
+		secret, err = auth.MakeToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't register the client", err)
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, err := cfg.db.CreateOAuthClient(req.Context(), database.CreateOAuthClientParams{
+		OwnerID:      userID,
+		Name:         params.Name,
+		SecretHash:   secretHash,
+		RedirectUris: params.RedirectURIs,
+		Scopes:       params.Scopes,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't register the client", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, returnVals{
+		OAuthClient:  databaseClientToClient(client),
+		ClientSecret: secret,
+	})
+}
+
+func (cfg *apiConfig) handlerOAuthClientsList(w http.ResponseWriter, req *http.Request) {
+	userID := callerOf(req).UserID
+
+	clients, err := cfg.db.ListOAuthClients(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list clients", err)
+		return
+	}
+
+	result := make([]OAuthClient, 0, len(clients))
+	for _, client := range clients {
+		result = append(result, databaseClientToClient(client))
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// handlerOAuthClientsDelete switches a client off. Its refresh tokens stop
+// working at once; access tokens it already holds run out on their own.
+func (cfg *apiConfig) handlerOAuthClientsDelete(w http.ResponseWriter, req *http.Request) {
+	userID := callerOf(req).UserID
+
+	clientID, err := uuid.Parse(req.PathValue("clientID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid client ID", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete the client", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	revoked, err := qtx.RevokeOAuthClient(req.Context(), database.RevokeOAuthClientParams{
+		ID:      clientID,
+		OwnerID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete the client", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find client", nil)
+		return
+	}
+
+	err = qtx.RevokeOAuthClientRefreshTokens(req.Context(), clientID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete the client", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete the client", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+)
+
+const (
+	// oauthAccessTokenTTL is shorter than for logins, since a client's
+	// access tokens outlive the client being switched off.
+	oauthAccessTokenTTL  = 15 * time.Minute
+	oauthRefreshTokenTTL = 30 * 24 * time.Hour
+
+	oauthCodeSweepInterval = 10 * time.Minute
+)
+
+var errInvalidClient = &oauthError{"invalid_client", "Client authentication failed"}
+
+// respondWithOAuthError answers the token, revocation and introspection
+// endpoints the way RFC 6749 section 5.2 asks.
+func respondWithOAuthError(w http.ResponseWriter, status int, oauthErr *oauthError, err error) {
+	if err != nil {
+		log.Println(err)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, status, struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}{
+		Error:            oauthErr.code,
+		ErrorDescription: oauthErr.description,
+	})
+}
+
+// authenticateClient identifies the client calling a back-channel endpoint,
+// from HTTP Basic credentials or client_id and client_secret in the form.
+// Public clients only send their client_id.
+func (cfg *apiConfig) authenticateClient(req *http.Request) (database.OauthClient, error) {
+	id, secret, basic := req.BasicAuth()
+	if !basic {
+		id = req.PostForm.Get("client_id")
+		secret = req.PostForm.Get("client_secret")
+	}
+
+	clientID, err := uuid.Parse(id)
+	if err != nil {
+		return database.OauthClient{}, errInvalidClient
+	}
+	client, err := cfg.db.GetOAuthClient(req.Context(), clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.OauthClient{}, errInvalidClient
+	}
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	if client.RevokedAt.Valid {
+		return database.OauthClient{}, errInvalidClient
+	}
+
+	if client.SecretHash.Valid {
+		if subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(client.SecretHash.String)) != 1 {
+			return database.OauthClient{}, errInvalidClient
+		}
+	} else if secret != "" {
+		return database.OauthClient{}, errInvalidClient
+	}
+	return client, nil
+}
+
+// respondToClientError answers a failed authenticateClient.
+func respondToClientError(w http.ResponseWriter, req *http.Request, err error) {
+	if !errors.Is(err, errInvalidClient) {
+		respondWithOAuthError(w, http.StatusInternalServerError, &oauthError{"server_error", "Couldn't authenticate the client"}, err)
+		return
+	}
+	if _, _, basic := req.BasicAuth(); basic {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	}
+	respondWithOAuthError(w, http.StatusUnauthorized, errInvalidClient, nil)
+}
+
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// handlerOAuthToken redeems an authorization code or a refresh token for a
+// new pair of tokens.
+func (cfg *apiConfig) handlerOAuthToken(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{"invalid_request", "Couldn't read the form"}, err)
+		return
+	}
+
+	client, err := cfg.authenticateClient(req)
+	if err != nil {
+		respondToClientError(w, req, err)
+		return
+	}
+
+	var tokens oauthTokenResponse
+	switch req.PostForm.Get("grant_type") {
+	case "authorization_code":
+		tokens, err = cfg.redeemAuthorizationCode(req, client)
+	case "refresh_token":
+		tokens, err = cfg.redeemOAuthRefreshToken(req, client)
+	default:
+		err = &oauthError{"unsupported_grant_type", "Use authorization_code or refresh_token"}
+	}
+	var oauthErr *oauthError
+	if errors.As(err, &oauthErr) {
+		respondWithOAuthError(w, http.StatusBadRequest, oauthErr, nil)
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, &oauthError{"server_error", "Couldn't issue tokens"}, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+// redeemAuthorizationCode exchanges a code for tokens once. The code only
+// works for the client it was issued to, from the same redirect URI and
+// with the PKCE verifier behind its challenge. A code that comes back a
+// second time was stolen, so what it was first exchanged for is revoked.
+func (cfg *apiConfig) redeemAuthorizationCode(req *http.Request, client database.OauthClient) (oauthTokenResponse, error) {
+	invalidGrant := &oauthError{"invalid_grant", "The authorization code is invalid or expired"}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	code, err := qtx.GetOAuthAuthorizationCodeForUpdate(req.Context(), auth.HashToken(req.PostForm.Get("code")))
+	if errors.Is(err, sql.ErrNoRows) {
+		return oauthTokenResponse{}, invalidGrant
+	}
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	if code.ClientID != client.ID {
+		return oauthTokenResponse{}, invalidGrant
+	}
+	if code.UsedAt.Valid {
+		err = qtx.RevokeOAuthRefreshTokenFamily(req.Context(), code.ID)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			return oauthTokenResponse{}, err
+		}
+		log.Printf("Authorization code %s of client %s was used again, revoked its tokens", code.ID, client.ID)
+		return oauthTokenResponse{}, invalidGrant
+	}
+	if time.Now().After(code.ExpiresAt) || req.PostForm.Get("redirect_uri") != code.RedirectUri {
+		return oauthTokenResponse{}, invalidGrant
+	}
+	err = auth.VerifyPKCE(req.PostForm.Get("code_verifier"), code.CodeChallenge)
+	if err != nil {
+		return oauthTokenResponse{}, invalidGrant
+	}
+
+	err = qtx.UseOAuthAuthorizationCode(req.Context(), code.ID)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	tokens, err := cfg.issueOAuthTokens(req.Context(), qtx, client, code.UserID, code.ID, code.Scopes, code.Scopes)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	return tokens, tx.Commit()
+}
+
+// redeemOAuthRefreshToken rotates a refresh token. As with logins, one that
+// was already rotated means it leaked, and its whole chain is revoked. The
+// client may ask for fewer scopes than it was granted.
+func (cfg *apiConfig) redeemOAuthRefreshToken(req *http.Request, client database.OauthClient) (oauthTokenResponse, error) {
+	invalidGrant := &oauthError{"invalid_grant", "The refresh token is invalid or expired"}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	refreshToken, err := qtx.GetOAuthRefreshTokenByHashForUpdate(req.Context(), auth.HashToken(req.PostForm.Get("refresh_token")))
+	if errors.Is(err, sql.ErrNoRows) {
+		return oauthTokenResponse{}, invalidGrant
+	}
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	if refreshToken.ClientID != client.ID {
+		return oauthTokenResponse{}, invalidGrant
+	}
+	if refreshToken.RevokedAt.Valid {
+		err = qtx.RevokeOAuthRefreshTokenFamily(req.Context(), refreshToken.FamilyID)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			return oauthTokenResponse{}, err
+		}
+		log.Printf("Revoked OAuth refresh token %s of client %s was used again, revoked its family", refreshToken.ID, client.ID)
+		return oauthTokenResponse{}, invalidGrant
+	}
+	if time.Now().After(refreshToken.ExpiresAt) {
+		return oauthTokenResponse{}, invalidGrant
+	}
+
+	scopes := refreshToken.Scopes
+	if req.PostForm.Has("scope") {
+		scopes = auth.ParseScope(req.PostForm.Get("scope"))
+		for _, scope := range scopes {
+			if !slices.Contains(refreshToken.Scopes, scope) {
+				return oauthTokenResponse{}, &oauthError{"invalid_scope", "The scope is wider than what was granted"}
+			}
+		}
+	}
+
+	err = qtx.RevokeOAuthRefreshToken(req.Context(), refreshToken.ID)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	tokens, err := cfg.issueOAuthTokens(req.Context(), qtx, client, refreshToken.UserID, refreshToken.FamilyID, scopes, refreshToken.Scopes)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	return tokens, tx.Commit()
+}
+
+// issueOAuthTokens mints an access token limited to scopes and a refresh
+// token that keeps the whole grant, so narrowing one access token doesn't
+// lose the rest.
+func (cfg *apiConfig) issueOAuthTokens(ctx context.Context, q *database.Queries, client database.OauthClient, userID, familyID uuid.UUID, scopes, granted []string) (oauthTokenResponse, error) {
+	user, err := q.GetUser(ctx, userID)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	accessToken, err := cfg.keyring.MakeClientJWT(user.ID, user.TokenVersion, client.ID.String(), scopes, oauthAccessTokenTTL)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	refreshToken, err := auth.MakeOAuthRefreshToken()
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	_, err = q.CreateOAuthRefreshToken(ctx, database.CreateOAuthRefreshTokenParams{
+		TokenHash: auth.HashToken(refreshToken),
+		ClientID:  client.ID,
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		Scopes:    granted,
+		ExpiresAt: time.Now().UTC().Add(oauthRefreshTokenTTL),
+	})
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+
+	return oauthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        auth.FormatScope(scopes),
+	}, nil
+}
+
+// handlerOAuthRevoke implements RFC 7009. Revoking a refresh token ends the
+// grant it belongs to. Tokens that are unknown or belong to another client
+// are ignored, and the answer is always 200 so it gives nothing away.
+func (cfg *apiConfig) handlerOAuthRevoke(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{"invalid_request", "Couldn't read the form"}, err)
+		return
+	}
+
+	client, err := cfg.authenticateClient(req)
+	if err != nil {
+		respondToClientError(w, req, err)
+		return
+	}
+
+	token := req.PostForm.Get("token")
+	if token == "" {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{"invalid_request", "The token parameter is required"}, nil)
+		return
+	}
+
+	if auth.IsOAuthRefreshToken(token) {
+		refreshToken, err := cfg.db.GetOAuthRefreshTokenByHash(req.Context(), auth.HashToken(token))
+		if err == nil && refreshToken.ClientID == client.ID {
+			err = cfg.db.RevokeOAuthRefreshTokenFamily(req.Context(), refreshToken.FamilyID)
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithOAuthError(w, http.StatusServiceUnavailable, &oauthError{"server_error", "Couldn't revoke the token"}, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	accessToken, err := cfg.keyring.ParseAccessToken(token)
+	if err == nil && accessToken.ClientID == client.ID.String() && accessToken.ID != uuid.Nil {
+		revocation, err := cfg.db.RevokeAccessToken(req.Context(), database.RevokeAccessTokenParams{
+			UserID:    accessToken.UserID,
+			TokenID:   uuid.NullUUID{UUID: accessToken.ID, Valid: true},
+			ExpiresAt: accessToken.ExpiresAt,
+		})
+		if err != nil {
+			respondWithOAuthError(w, http.StatusServiceUnavailable, &oauthError{"server_error", "Couldn't revoke the token"}, err)
+			return
+		}
+		cfg.announceRevocation(revocation)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// introspection is the answer of RFC 7662. Everything but Active is left
+// out for tokens that aren't.
+type introspection struct {
+	Active    bool       `json:"active"`
+	Scope     string     `json:"scope,omitempty"`
+	ClientID  string     `json:"client_id,omitempty"`
+	Subject   *uuid.UUID `json:"sub,omitempty"`
+	TokenType string     `json:"token_type,omitempty"`
+	ExpiresAt int64      `json:"exp,omitempty"`
+	IssuedAt  int64      `json:"iat,omitempty"`
+	TokenID   string     `json:"jti,omitempty"`
+	Issuer    string     `json:"iss,omitempty"`
+}
+
+// handlerOAuthIntrospect implements RFC 7662 for the calling client's own
+// tokens. Anything else, including tokens of other clients, is reported as
+// inactive.
+func (cfg *apiConfig) handlerOAuthIntrospect(w http.ResponseWriter, req *http.Request) {
+	err := req.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{"invalid_request", "Couldn't read the form"}, err)
+		return
+	}
+
+	client, err := cfg.authenticateClient(req)
+	if err != nil {
+		respondToClientError(w, req, err)
+		return
+	}
+
+	token := req.PostForm.Get("token")
+	if token == "" {
+		respondWithOAuthError(w, http.StatusBadRequest, &oauthError{"invalid_request", "The token parameter is required"}, nil)
+		return
+	}
+
+	result := introspection{}
+	if auth.IsOAuthRefreshToken(token) {
+		refreshToken, err := cfg.db.GetOAuthRefreshTokenByHash(req.Context(), auth.HashToken(token))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithOAuthError(w, http.StatusInternalServerError, &oauthError{"server_error", "Couldn't look the token up"}, err)
+			return
+		}
+		if err == nil && refreshToken.ClientID == client.ID && !refreshToken.RevokedAt.Valid && time.Now().Before(refreshToken.ExpiresAt) {
+			result = introspection{
+				Active:    true,
+				Scope:     auth.FormatScope(refreshToken.Scopes),
+				ClientID:  client.ID.String(),
+				Subject:   &refreshToken.UserID,
+				TokenType: "refresh_token",
+				ExpiresAt: refreshToken.ExpiresAt.Unix(),
+				IssuedAt:  refreshToken.CreatedAt.Unix(),
+			}
+		}
+	} else if accessToken, err := cfg.keyring.ParseAccessToken(token); err == nil && accessToken.ClientID == client.ID.String() {
+		result = introspection{
+			Active:    true,
+			Scope:     auth.FormatScope(accessToken.Scopes),
+			ClientID:  accessToken.ClientID,
+			Subject:   &accessToken.UserID,
+			TokenType: "access_token",
+			ExpiresAt: accessToken.ExpiresAt.Unix(),
+			IssuedAt:  accessToken.IssuedAt.Unix(),
+			TokenID:   accessToken.ID.String(),
+			Issuer:    "chirpy",
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// handlerOAuthMetadata publishes the server's endpoints and capabilities
+// as in RFC 8414, so clients don't have to hardcode them.
+func (cfg *apiConfig) handlerOAuthMetadata(w http.ResponseWriter, req *http.Request) {
+	type metadata struct {
+		Issuer                            string   `json:"issuer"`
+		AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+		TokenEndpoint                     string   `json:"token_endpoint"`
+		RevocationEndpoint                string   `json:"revocation_endpoint"`
+		IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+		JWKSURI                           string   `json:"jwks_uri"`
+		ScopesSupported                   []string `json:"scopes_supported"`
+		ResponseTypesSupported            []string `json:"response_types_supported"`
+		GrantTypesSupported               []string `json:"grant_types_supported"`
+		CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+		TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, metadata{
+		Issuer:                            cfg.publicURL,
+		AuthorizationEndpoint:             cfg.publicURL + "/oauth/authorize",
+		TokenEndpoint:                     cfg.publicURL + "/oauth/token",
+		RevocationEndpoint:                cfg.publicURL + "/oauth/revoke",
+		IntrospectionEndpoint:             cfg.publicURL + "/oauth/introspect",
+		JWKSURI:                           cfg.publicURL + "/.well-known/jwks.json",
+		ScopesSupported:                   auth.Scopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		CodeChallengeMethodsSupported:     []string{auth.CodeChallengeMethodS256},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
+
+// runOAuthCodeSweeper deletes authorization codes some time after they
+// expired. They are kept for a day so a late replay is still recognised.
+func (cfg *apiConfig) runOAuthCodeSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := cfg.db.DeleteExpiredOAuthAuthorizationCodes(ctx, time.Now().UTC().Add(-24*time.Hour))
+			if err != nil {
+				log.Printf("Couldn't delete expired authorization codes: %s", err)
+			}
+		}
+	}
+}
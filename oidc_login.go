@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/oidc"
+)
+
+const (
+	// oidcLoginTTL is how long the user has to sign in at the provider.
+	oidcLoginTTL = 10 * time.Minute
+	// oidcSignInTTL is how long the client has to trade a finished sign-in
+	// for tokens; it does so right after the redirect.
+	oidcSignInTTL          = time.Minute
+	oidcLoginSweepInterval = 10 * time.Minute
+	oidcStateCookie        = "chirpy_oidc_state"
+	oidcCookiePath         = "/api/oidc"
+)
+
+var (
+	errIdentityEmailUnverified = errors.New("the identity provider didn't confirm the email address")
+	errAccountEmailUnverified  = errors.New("the account with this email hasn't confirmed it")
+)
+
+// handlerOIDCLogin sends the browser to the identity provider. The state is
+// also kept in a cookie, so a sign-in can only be finished by the browser
+// that started it and nobody can slip their own account into someone
+// else's browser.
+func (cfg *apiConfig) handlerOIDCLogin(w http.ResponseWriter, req *http.Request) {
+	state, err := auth.MakeToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start the sign-in", err)
+		return
+	}
+	nonce, err := auth.MakeToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start the sign-in", err)
+		return
+	}
+	verifier, err := auth.MakeToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start the sign-in", err)
+		return
+	}
+
+	authURL, err := cfg.oidc.AuthCodeURL(req.Context(), state, nonce, auth.CodeChallenge(verifier))
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "Couldn't reach the identity provider", err)
+		return
+	}
+
+	err = cfg.db.CreateOIDCLogin(req.Context(), database.CreateOIDCLoginParams{
+		StateHash:    auth.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().UTC().Add(oidcLoginTTL),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start the sign-in", err)
+		return
+	}
+
+	http.SetCookie(w, cfg.oidcStateCookie(state, int(oidcLoginTTL.Seconds())))
+	http.Redirect(w, req, authURL, http.StatusFound)
+}
+
+// handlerOIDCCallback finishes a sign-in when the provider sends the
+// browser back. The browser is then sent on to the client with a one-time
+// code, or an error, in the query; tokens never travel in a URL or land on
+// a page the client doesn't control.
+func (cfg *apiConfig) handlerOIDCCallback(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	state := query.Get("state")
+
+	cookie, err := req.Cookie(oidcStateCookie)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		cfg.oidcReturn(w, req, "invalid_request", "This sign-in wasn't started from this browser", err)
+		return
+	}
+	http.SetCookie(w, cfg.oidcStateCookie("", -1))
+
+	// Taking the login deletes it, so the same state can't be used twice.
+	login, err := cfg.db.TakeOIDCLogin(req.Context(), auth.HashToken(state))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && time.Now().After(login.ExpiresAt)) {
+		cfg.oidcReturn(w, req, "invalid_request", "The sign-in expired, please start again", err)
+		return
+	}
+	if err != nil {
+		cfg.oidcReturn(w, req, "server_error", "Couldn't sign in", err)
+		return
+	}
+
+	if providerErr := query.Get("error"); providerErr != "" {
+		cfg.oidcReturn(w, req, "access_denied", "The identity provider refused the sign-in: "+providerErr, nil)
+		return
+	}
+
+	rawIDToken, err := cfg.oidc.Exchange(req.Context(), query.Get("code"), login.CodeVerifier)
+	if err != nil {
+		cfg.oidcReturn(w, req, "temporarily_unavailable", "Couldn't sign in with the identity provider", err)
+		return
+	}
+	idToken, err := cfg.oidc.VerifyIDToken(req.Context(), rawIDToken, login.Nonce)
+	if err != nil {
+		cfg.oidcReturn(w, req, "access_denied", "The identity provider's answer is invalid", err)
+		return
+	}
+
+	user, err := cfg.userForIdentity(req.Context(), idToken)
+	if errors.Is(err, errIdentityEmailUnverified) {
+		cfg.oidcReturn(w, req, "access_denied", "The identity provider didn't confirm your email address", nil)
+		return
+	}
+	if errors.Is(err, errAccountEmailUnverified) {
+		cfg.oidcReturn(w, req, "access_denied", "An account with this email exists but hasn't confirmed it; sign in with your password and confirm it first", nil)
+		return
+	}
+	if isUniqueViolation(err) {
+		cfg.oidcReturn(w, req, "server_error", "Couldn't sign in, please try again", err)
+		return
+	}
+	if err != nil {
+		cfg.oidcReturn(w, req, "server_error", "Couldn't sign in", err)
+		return
+	}
+
+	code, err := auth.MakeToken()
+	if err != nil {
+		cfg.oidcReturn(w, req, "server_error", "Couldn't sign in", err)
+		return
+	}
+	err = cfg.db.CreateOIDCSignIn(req.Context(), database.CreateOIDCSignInParams{
+		CodeHash:  auth.HashToken(code),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(oidcSignInTTL),
+	})
+	if err != nil {
+		cfg.oidcReturn(w, req, "server_error", "Couldn't sign in", err)
+		return
+	}
+
+	cfg.redirectToOIDCReturn(w, req, url.Values{"code": {code}})
+}
+
+// oidcReturn sends the browser back to the client with an error, using the
+// codes of RFC 6749.
+func (cfg *apiConfig) oidcReturn(w http.ResponseWriter, req *http.Request, code, description string, err error) {
+	if err != nil {
+		log.Printf("Sign-in with the identity provider failed: %s: %s", description, err)
+	}
+	cfg.redirectToOIDCReturn(w, req, url.Values{
+		"error":             {code},
+		"error_description": {description},
+	})
+}
+
+func (cfg *apiConfig) redirectToOIDCReturn(w http.ResponseWriter, req *http.Request, params url.Values) {
+	target := *cfg.oidcReturnURL
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	target.RawQuery = query.Encode()
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	http.Redirect(w, req, target.String(), http.StatusFound)
+}
+
+// handlerOIDCToken trades the code from a finished sign-in for tokens. It
+// answers like POST /api/login: with tokens, or with a challenge when the
+// account has a second factor.
+func (cfg *apiConfig) handlerOIDCToken(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Taking the sign-in deletes it, so the code works once.
+	signIn, err := cfg.db.TakeOIDCSignIn(req.Context(), auth.HashToken(params.Code))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && time.Now().After(signIn.ExpiresAt)) {
+		respondWithError(w, http.StatusBadRequest, "Invalid or expired sign-in code", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't sign in", err)
+		return
+	}
+
+	user, err := cfg.db.GetUser(req.Context(), signIn.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't sign in", err)
+		return
+	}
+
+	enabled, err := cfg.hasTwoFactor(req.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't sign in", err)
+		return
+	}
+	if enabled {
+		challenge, err := cfg.startLoginChallenge(req.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't sign in", err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, challenge)
+		return
+	}
+
+	session, err := cfg.startSession(req.Context(), user, cfg.deviceOf(req))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't sign in", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, session)
+}
+
+func (cfg *apiConfig) oidcStateCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     oidcCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.publicURL, "https://"),
+		// Lax still sends it on the provider's top-level redirect back.
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// userForIdentity finds the account an identity belongs to. An identity
+// seen before keeps its account even if its email changes. A new one is
+// linked by email, which both sides must have confirmed: otherwise whoever
+// signed up with someone else's address could wait for them to sign in.
+// Without an account a new one is made, with its email already confirmed.
+func (cfg *apiConfig) userForIdentity(ctx context.Context, idToken oidc.IDToken) (database.User, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	identity, err := qtx.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+	})
+	if err == nil {
+		email := idToken.Email
+		if email == "" {
+			email = identity.Email
+		}
+		err = qtx.TouchUserIdentity(ctx, database.TouchUserIdentityParams{
+			Issuer:  identity.Issuer,
+			Subject: identity.Subject,
+			Email:   email,
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+		user, err := qtx.GetUser(ctx, identity.UserID)
+		if err != nil {
+			return database.User{}, err
+		}
+		return user, tx.Commit()
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if idToken.Email == "" || !idToken.EmailVerified {
+		return database.User{}, errIdentityEmailUnverified
+	}
+
+	created := false
+	user, err := qtx.GetUserByEmail(ctx, idToken.Email)
+	switch {
+	case err == nil:
+		if !user.EmailVerifiedAt.Valid {
+			return database.User{}, errAccountEmailUnverified
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		user, err = provisionUser(ctx, qtx, idToken.Email)
+		if err != nil {
+			return database.User{}, err
+		}
+		created = true
+	default:
+		return database.User{}, err
+	}
+
+	_, err = qtx.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		UserID:  user.ID,
+		Email:   idToken.Email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return database.User{}, err
+	}
+	if created {
+		cfg.publish(eventUserCreated, user.ID, databaseUserToUser(user))
+		log.Printf("Created user %s for %s at %s", user.ID, idToken.Subject, idToken.Issuer)
+	} else {
+		log.Printf("Linked %s at %s to user %s", idToken.Subject, idToken.Issuer, user.ID)
+	}
+	return user, nil
+}
+
+// provisionUser makes the account for someone signing in with the provider
+// for the first time. Its password is random and never shown; a password
+// reset sets one if the user ever wants to sign in without the provider.
+func provisionUser(ctx context.Context, q *database.Queries, email string) (database.User, error) {
+	password, err := auth.MakeToken()
+	if err != nil {
+		return database.User{}, err
+	}
+	hashed, err := auth.HashPassword(password)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	user, err := q.CreateUser(ctx, database.CreateUserParams{
+		Email:          email,
+		HashedPassword: hashed,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return q.ConfirmUserEmail(ctx, database.ConfirmUserEmailParams{
+		ID:    user.ID,
+		Email: email,
+	})
+}
+
+func (cfg *apiConfig) runOIDCLoginSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := cfg.db.DeleteExpiredOIDCLogins(ctx, time.Now().UTC())
+			if err != nil {
+				log.Printf("Couldn't delete expired sign-ins: %s", err)
+			}
+			_, err = cfg.db.DeleteExpiredOIDCSignIns(ctx, time.Now().UTC())
+			if err != nil {
+				log.Printf("Couldn't delete unused sign-in codes: %s", err)
+			}
+		}
+	}
+}
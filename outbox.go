@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/mailer"
+)
+
+const (
+	outboxInterval    = 5 * time.Second
+	outboxBatchSize   = 20
+	maxEmailAttempts  = 8
+	maxEmailRetryWait = time.Hour
+)
+
+// enqueueEmail adds a message to the outbox. Passing the transaction's
+// queries means the message only goes out if the change it announces is
+// committed.
+func enqueueEmail(ctx context.Context, q *database.Queries, msg mailer.Message) error {
+	return q.EnqueueEmail(ctx, database.EnqueueEmailParams{
+		ToAddress: msg.To,
+		Subject:   msg.Subject,
+		Body:      msg.Body,
+	})
+}
+
+// runEmailOutbox hands queued messages to the mailer until ctx is
+// cancelled. Rows are claimed with SKIP LOCKED, so every instance can run
+// it without sending anything twice.
+func (cfg *apiConfig) runEmailOutbox(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := cfg.deliverPendingEmails(ctx)
+			if err != nil {
+				log.Printf("Couldn't deliver queued email: %s", err)
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) deliverPendingEmails(ctx context.Context) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	pending, err := qtx.ClaimPendingEmails(ctx, database.ClaimPendingEmailsParams{
+		Attempts: maxEmailAttempts,
+		Limit:    outboxBatchSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, email := range pending {
+		err := cfg.mailer.Send(ctx, mailer.Message{
+			To:      email.ToAddress,
+			Subject: email.Subject,
+			Body:    email.Body,
+		})
+		if err != nil {
+			log.Printf("Couldn't send email %s: %s", email.ID, err)
+			// Back off exponentially: one minute, two, four, up to an hour.
+			wait := min(time.Minute<<email.Attempts, maxEmailRetryWait)
+			err = qtx.MarkEmailFailed(ctx, database.MarkEmailFailedParams{
+				ID:            email.ID,
+				LastError:     sql.NullString{String: err.Error(), Valid: true},
+				NextAttemptAt: time.Now().UTC().Add(wait),
+			})
+		} else {
+			err = qtx.MarkEmailSent(ctx, email.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// renderPage serves one of the few pages the server renders itself. They
+// mustn't be framed, or another site could trick the user into clicking
+// through them, and the tokens some of them carry in the URL mustn't leak
+// in a Referer.
+func renderPage(w http.ResponseWriter, status int, tmpl *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+	err := tmpl.Execute(w, data)
+	if err != nil {
+		log.Printf("Couldn't render the %s page: %s", tmpl.Name(), err)
+	}
+}
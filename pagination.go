@@ -0,0 +1,187 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+// pageCursor points at the last row a client has seen. It is handed out as
+// an opaque base64 string so the encoding can change without breaking links.
+type pageCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	Rank      float32   `json:"r,omitempty"`
+	Prev      bool      `json:"p,omitempty"`
+}
+
+func (c pageCursor) String() string {
+	dat, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(dat)
+}
+
+func parsePageCursor(s string) (pageCursor, error) {
+	dat, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, err
+	}
+	c := pageCursor{}
+	err = json.Unmarshal(dat, &c)
+	if err != nil {
+		return pageCursor{}, err
+	}
+	if c.ID == uuid.Nil || c.CreatedAt.IsZero() {
+		return pageCursor{}, fmt.Errorf("incomplete cursor")
+	}
+	return c, nil
+}
+
+type pageParams struct {
+	Limit  int32
+	Cursor *pageCursor
+	Since  sql.NullTime
+	Until  sql.NullTime
+	Desc   bool
+}
+
+func parsePageParams(query url.Values) (pageParams, error) {
+	p := pageParams{Limit: defaultPageLimit}
+
+	if s := query.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			return pageParams{}, fmt.Errorf("invalid limit %q", s)
+		}
+		p.Limit = int32(min(limit, maxPageLimit))
+	}
+
+	if s := query.Get("cursor"); s != "" {
+		cursor, err := parsePageCursor(s)
+		if err != nil {
+			return pageParams{}, fmt.Errorf("invalid cursor: %w", err)
+		}
+		p.Cursor = &cursor
+	}
+
+	for _, bound := range []struct {
+		name string
+		dst  *sql.NullTime
+	}{
+		{"since", &p.Since},
+		{"until", &p.Until},
+	} {
+		s := query.Get(bound.name)
+		if s == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return pageParams{}, fmt.Errorf("invalid %s: %w", bound.name, err)
+		}
+		*bound.dst = sql.NullTime{Time: t.UTC(), Valid: true}
+	}
+
+	switch sortMethod := query.Get("sort"); sortMethod {
+	case "", "asc":
+	case "desc":
+		p.Desc = true
+	default:
+		return pageParams{}, fmt.Errorf("invalid sort %q", sortMethod)
+	}
+
+	return p, nil
+}
+
+// queryDesc is the order rows must be fetched in. Walking back to a previous
+// page reads the opposite way from the cursor and flips the rows afterwards.
+func (p pageParams) queryDesc() bool {
+	if p.Cursor != nil && p.Cursor.Prev {
+		return !p.Desc
+	}
+	return p.Desc
+}
+
+// queryLimit fetches one extra row to find out whether another page follows.
+func (p pageParams) queryLimit() int32 {
+	return p.Limit + 1
+}
+
+func (p pageParams) cursorArgs() (sql.NullTime, uuid.NullUUID) {
+	if p.Cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: p.Cursor.CreatedAt, Valid: true}, uuid.NullUUID{UUID: p.Cursor.ID, Valid: true}
+}
+
+// paginate turns rows fetched with queryDesc and queryLimit into a page in
+// the requested order, along with the cursors for its neighbours.
+func paginate[T any](rows []T, p pageParams, key func(T) pageCursor) (page []T, next, prev *pageCursor) {
+	hasMore := len(rows) > int(p.Limit)
+	if hasMore {
+		rows = rows[:p.Limit]
+	}
+
+	backwards := p.Cursor != nil && p.Cursor.Prev
+	if backwards {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	if len(rows) == 0 {
+		return rows, nil, nil
+	}
+
+	first, last := key(rows[0]), key(rows[len(rows)-1])
+	first.Prev = true
+	if backwards {
+		next = &last
+		if hasMore {
+			prev = &first
+		}
+	} else {
+		if hasMore {
+			next = &last
+		}
+		if p.Cursor != nil {
+			prev = &first
+		}
+	}
+	return rows, next, prev
+}
+
+// setPageLinks advertises the neighbouring pages in an RFC 8288 Link header,
+// keeping every other query parameter of the current request.
+func setPageLinks(w http.ResponseWriter, req *http.Request, next, prev *pageCursor) {
+	links := []string{}
+	for _, link := range []struct {
+		rel    string
+		cursor *pageCursor
+	}{
+		{"next", next},
+		{"prev", prev},
+	} {
+		if link.cursor == nil {
+			continue
+		}
+		query := req.URL.Query()
+		query.Set("cursor", link.cursor.String())
+		u := url.URL{Path: req.URL.Path, RawQuery: query.Encode()}
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.String(), link.rel))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPageCursorRoundTrip(t *testing.T) {
+	want := pageCursor{
+		CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		ID:        uuid.New(),
+		Rank:      0.5,
+		Prev:      true,
+	}
+	got, err := parsePageCursor(want.String())
+	if err != nil {
+		t.Fatalf("parsePageCursor: %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID || got.Rank != want.Rank || got.Prev != want.Prev {
+		t.Fatalf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestParsePageCursorRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!"},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("nope"))},
+		{"missing id", pageCursor{CreatedAt: time.Now()}.String()},
+		{"missing time", pageCursor{ID: uuid.New()}.String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parsePageCursor(tt.cursor); err == nil {
+				t.Fatalf("parsePageCursor(%q) succeeded, want an error", tt.cursor)
+			}
+		})
+	}
+}
+
+func TestParsePageParams(t *testing.T) {
+	cursor := pageCursor{CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ID: uuid.New()}
+	since := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantErr    bool
+		wantLimit  int32
+		wantCursor *pageCursor
+		wantSince  time.Time
+		wantUntil  time.Time
+		wantDesc   bool
+	}{
+		{name: "defaults", query: "", wantLimit: defaultPageLimit},
+		{name: "limit", query: "limit=10", wantLimit: 10},
+		{name: "limit is capped", query: "limit=500", wantLimit: maxPageLimit},
+		{name: "zero limit", query: "limit=0", wantErr: true},
+		{name: "limit not a number", query: "limit=ten", wantErr: true},
+		{name: "cursor", query: "cursor=" + cursor.String(), wantLimit: defaultPageLimit, wantCursor: &cursor},
+		{name: "bad cursor", query: "cursor=abc", wantErr: true},
+		{name: "since", query: "since=2024-05-01T10:00:00Z", wantLimit: defaultPageLimit, wantSince: since},
+		{name: "until in another zone", query: "until=" + url.QueryEscape("2024-05-01T12:00:00+02:00"), wantLimit: defaultPageLimit, wantUntil: since},
+		{name: "bad since", query: "since=yesterday", wantErr: true},
+		{name: "bad until", query: "until=2024-05-01", wantErr: true},
+		{name: "ascending", query: "sort=asc", wantLimit: defaultPageLimit},
+		{name: "descending", query: "sort=desc", wantLimit: defaultPageLimit, wantDesc: true},
+		{name: "unknown sort", query: "sort=random", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("bad test query: %v", err)
+			}
+			p, err := parsePageParams(query)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePageParams(%q) succeeded, want an error", tt.query)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePageParams(%q): %v", tt.query, err)
+			}
+
+			if p.Limit != tt.wantLimit {
+				t.Errorf("Limit = %d, want %d", p.Limit, tt.wantLimit)
+			}
+			if p.Desc != tt.wantDesc {
+				t.Errorf("Desc = %t, want %t", p.Desc, tt.wantDesc)
+			}
+			switch {
+			case tt.wantCursor == nil && p.Cursor != nil:
+				t.Errorf("Cursor = %+v, want none", *p.Cursor)
+			case tt.wantCursor != nil && (p.Cursor == nil || p.Cursor.ID != tt.wantCursor.ID || !p.Cursor.CreatedAt.Equal(tt.wantCursor.CreatedAt)):
+				t.Errorf("Cursor = %v, want %+v", p.Cursor, *tt.wantCursor)
+			}
+			if p.Since.Valid != !tt.wantSince.IsZero() || !p.Since.Time.Equal(tt.wantSince) {
+				t.Errorf("Since = %+v, want %v", p.Since, tt.wantSince)
+			}
+			if p.Until.Valid != !tt.wantUntil.IsZero() || !p.Until.Time.Equal(tt.wantUntil) {
+				t.Errorf("Until = %+v, want %v", p.Until, tt.wantUntil)
+			}
+			if p.Until.Valid && p.Until.Time.Location() != time.UTC {
+				t.Errorf("Until is in %s, want UTC", p.Until.Time.Location())
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	// Row n was created n minutes after base; its ID is fixed so cursors
+	// can be compared.
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	ids := map[int]uuid.UUID{}
+	key := func(n int) pageCursor {
+		if _, ok := ids[n]; !ok {
+			ids[n] = uuid.New()
+		}
+		return pageCursor{CreatedAt: base.Add(time.Duration(n) * time.Minute), ID: ids[n]}
+	}
+	prevOf := func(n int) *pageCursor {
+		c := key(n)
+		c.Prev = true
+		return &c
+	}
+	nextOf := func(n int) *pageCursor {
+		c := key(n)
+		return &c
+	}
+	forward := nextOf(2)
+	backward := prevOf(5)
+
+	tests := []struct {
+		name     string
+		rows     []int
+		cursor   *pageCursor
+		wantPage []int
+		wantNext *pageCursor
+		wantPrev *pageCursor
+	}{
+		{
+			name:     "first page with more",
+			rows:     []int{1, 2, 3},
+			wantPage: []int{1, 2},
+			wantNext: nextOf(2),
+		},
+		{
+			name:     "only page",
+			rows:     []int{1, 2},
+			wantPage: []int{1, 2},
+		},
+		{
+			name:     "empty",
+			rows:     []int{},
+			wantPage: []int{},
+		},
+		{
+			name:     "middle page",
+			rows:     []int{3, 4, 5},
+			cursor:   forward,
+			wantPage: []int{3, 4},
+			wantNext: nextOf(4),
+			wantPrev: prevOf(3),
+		},
+		{
+			name:     "last page",
+			rows:     []int{3},
+			cursor:   forward,
+			wantPage: []int{3},
+			wantPrev: prevOf(3),
+		},
+		{
+			// Walking back fetches rows the other way round, nearest first.
+			name:     "previous page with more",
+			rows:     []int{4, 3, 2},
+			cursor:   backward,
+			wantPage: []int{3, 4},
+			wantNext: nextOf(4),
+			wantPrev: prevOf(3),
+		},
+		{
+			name:     "previous page reaches the start",
+			rows:     []int{2, 1},
+			cursor:   backward,
+			wantPage: []int{1, 2},
+			wantNext: nextOf(2),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := pageParams{Limit: 2, Cursor: tt.cursor}
+			page, next, prev := paginate(slices.Clone(tt.rows), p, key)
+
+			if !slices.Equal(page, tt.wantPage) {
+				t.Errorf("page = %v, want %v", page, tt.wantPage)
+			}
+			if !sameCursor(next, tt.wantNext) {
+				t.Errorf("next = %v, want %v", next, tt.wantNext)
+			}
+			if !sameCursor(prev, tt.wantPrev) {
+				t.Errorf("prev = %v, want %v", prev, tt.wantPrev)
+			}
+		})
+	}
+}
+
+func sameCursor(a, b *pageCursor) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ID == b.ID && a.CreatedAt.Equal(b.CreatedAt) && a.Rank == b.Rank && a.Prev == b.Prev
+}
+
+func TestSetPageLinks(t *testing.T) {
+	next := &pageCursor{CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ID: uuid.New()}
+	prev := &pageCursor{CreatedAt: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), ID: uuid.New(), Prev: true}
+	nextLink := fmt.Sprintf(`</api/chirps?author_id=abc&cursor=%s&limit=2>; rel="next"`, next)
+	prevLink := fmt.Sprintf(`</api/chirps?author_id=abc&cursor=%s&limit=2>; rel="prev"`, prev)
+
+	tests := []struct {
+		name string
+		next *pageCursor
+		prev *pageCursor
+		want string
+	}{
+		{"no neighbours", nil, nil, ""},
+		{"next only", next, nil, nextLink},
+		{"prev only", nil, prev, prevLink},
+		{"both", next, prev, nextLink + ", " + prevLink},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The old cursor is replaced and every other parameter kept.
+			req := httptest.NewRequest(http.MethodGet, "/api/chirps?author_id=abc&limit=2&cursor=old", nil)
+			w := httptest.NewRecorder()
+			setPageLinks(w, req, tt.next, tt.prev)
+
+			if got := w.Header().Get("Link"); got != tt.want {
+				t.Errorf("Link = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
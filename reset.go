@@ -10,7 +10,10 @@ func (cfg *apiConfig) handlerReset(w http.ResponseWriter, req *http.Request) {
 	}
 
 	cfg.fileserverHits.Store(0)
-	err := cfg.db.Reset(req.Context())
+	err := cfg.db.ResetFileserverHits(req.Context())
+	if err == nil {
+		err = cfg.db.Reset(req.Context())
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("could't reset the database: " + err.Error()))
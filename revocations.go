@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/pubsub"
+)
+
+const (
+	// revocationSyncInterval is how often the table is re-read to catch
+	// revocations whose event was missed, e.g. while the listener was
+	// reconnecting. It bounds how long another instance can keep accepting
+	// a revoked token in that case.
+	revocationSyncInterval = 30 * time.Second
+	// revocationLookback re-reads recent revocations on every sync, so one
+	// from a transaction that committed late or a clock slightly behind
+	// isn't skipped.
+	revocationLookback      = time.Minute
+	revocationPruneInterval = time.Minute
+)
+
+// applyRevocation adds a revocation to this instance's denylist.
+func (cfg *apiConfig) applyRevocation(r database.AccessTokenRevocation) {
+	if r.TokenID.Valid {
+		cfg.revocations.RevokeToken(r.TokenID.UUID, r.ExpiresAt)
+	}
+	if r.MinVersion.Valid {
+		cfg.revocations.RevokeVersionsBelow(r.UserID, r.MinVersion.Int32, r.ExpiresAt)
+	}
+}
+
+// announceRevocation applies a committed revocation here and sends it to
+// the other instances, which apply it as soon as they hear of it.
+func (cfg *apiConfig) announceRevocation(r database.AccessTokenRevocation) {
+	cfg.applyRevocation(r)
+
+	event := tokenRevokedEvent{
+		UserID:    r.UserID,
+		TokenID:   r.TokenID,
+		ExpiresAt: r.ExpiresAt,
+	}
+	if r.MinVersion.Valid {
+		event.MinVersion = &r.MinVersion.Int32
+	}
+	cfg.publish(eventTokenRevoked, r.UserID, event)
+}
+
+// runRevocationListener applies the revocations announced on the event bus
+// until ctx is cancelled. If it falls behind it is dropped by the bus and
+// subscribes again; whatever it missed is left to runRevocationSync.
+func (cfg *apiConfig) runRevocationListener(ctx context.Context) {
+	filter := func(event pubsub.Event) bool {
+		return event.Type == eventTokenRevoked
+	}
+
+	for ctx.Err() == nil {
+		sub, _, err := cfg.events.Subscribe(0, filter)
+		if err != nil {
+			log.Printf("Couldn't subscribe to token revocations: %s", err)
+			return
+		}
+		cfg.applyRevocationEvents(ctx, sub)
+	}
+}
+
+// applyRevocationEvents applies events from sub until ctx is cancelled or
+// the bus drops the subscription.
+func (cfg *apiConfig) applyRevocationEvents(ctx context.Context, sub *pubsub.Subscription) {
+	defer cfg.events.Unsubscribe(sub)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			cfg.applyRevocationEvent(event)
+		}
+	}
+}
+
+func (cfg *apiConfig) applyRevocationEvent(event pubsub.Event) {
+	data := tokenRevokedEvent{}
+	err := json.Unmarshal(event.Data, &data)
+	if err != nil {
+		log.Printf("Couldn't decode a token revocation: %s", err)
+		return
+	}
+
+	r := database.AccessTokenRevocation{
+		UserID:    data.UserID,
+		TokenID:   data.TokenID,
+		ExpiresAt: data.ExpiresAt,
+	}
+	if data.MinVersion != nil {
+		r.MinVersion = sql.NullInt32{Int32: *data.MinVersion, Valid: true}
+	}
+	cfg.applyRevocation(r)
+}
+
+// revokeUserTokens bumps the user's token version, which revokes every
+// access token issued so far. Apply the returned revocation once q's
+// transaction has committed with announceRevocation.
+func revokeUserTokens(ctx context.Context, q *database.Queries, userID uuid.UUID) (database.AccessTokenRevocation, error) {
+	return q.BumpUserTokenVersion(ctx, database.BumpUserTokenVersionParams{
+		ID:        userID,
+		ExpiresAt: time.Now().UTC().Add(accessTokenTTL),
+	})
+}
+
+// runRevocationSync keeps the denylist in step with the
+// access_token_revocations table until ctx is cancelled, backing up
+// runRevocationListener. The first pass loads everything that hasn't
+// expired yet, which covers revocations made before this instance started.
+func (cfg *apiConfig) runRevocationSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	since := time.Time{}
+	lastPrune := time.Now()
+	for {
+		started := time.Now().UTC()
+		revocations, err := cfg.db.ListAccessTokenRevocationsSince(ctx, since)
+		if err != nil {
+			log.Printf("Couldn't sync access token revocations: %s", err)
+		} else {
+			for _, r := range revocations {
+				cfg.applyRevocation(r)
+			}
+			since = started.Add(-revocationLookback)
+		}
+
+		if time.Since(lastPrune) >= revocationPruneInterval {
+			lastPrune = time.Now()
+			cfg.revocations.Prune(lastPrune)
+			_, err := cfg.db.DeleteExpiredAccessTokenRevocations(ctx)
+			if err != nil {
+				log.Printf("Couldn't delete expired access token revocations: %s", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
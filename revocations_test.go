@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/pubsub"
+)
+
+func TestRevocationReachesOtherInstances(t *testing.T) {
+	// Two instances sharing a bus, as they would through Postgres.
+	bus := pubsub.NewBroker(100, 16)
+	revoker := &apiConfig{events: bus, revocations: auth.NewRevocationList()}
+	other := &apiConfig{events: bus, revocations: auth.NewRevocationList()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go other.runRevocationListener(ctx)
+
+	userID := uuid.New()
+	tokenID := uuid.New()
+	expiresAt := time.Now().Add(time.Hour)
+
+	// Subscribing happens in the background, so keep announcing until the
+	// listener has caught them; applying a revocation twice is harmless.
+	// The floor is announced first, so it has arrived once the jti has.
+	deadline := time.Now().Add(5 * time.Second)
+	for !other.revocations.Revoked(auth.AccessToken{ID: tokenID, UserID: userID, TokenVersion: 2}) {
+		if time.Now().After(deadline) {
+			t.Fatal("the other instance never applied the revocation")
+		}
+		revoker.announceRevocation(database.AccessTokenRevocation{
+			UserID:     userID,
+			MinVersion: sql.NullInt32{Int32: 2, Valid: true},
+			ExpiresAt:  expiresAt,
+		})
+		revoker.announceRevocation(database.AccessTokenRevocation{
+			UserID:    userID,
+			TokenID:   uuid.NullUUID{UUID: tokenID, Valid: true},
+			ExpiresAt: expiresAt,
+		})
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if !other.revocations.Revoked(auth.AccessToken{ID: uuid.New(), UserID: userID, TokenVersion: 1}) {
+		t.Error("the version floor didn't reach the other instance")
+	}
+	if other.revocations.Revoked(auth.AccessToken{ID: uuid.New(), UserID: userID, TokenVersion: 2}) {
+		t.Error("a token above the floor was refused")
+	}
+}
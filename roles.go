@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+)
+
+// roleAdmin is the role that can hand out roles, so there must always be
+// one account holding it.
+const roleAdmin = "admin"
+
+// Permissions are what roles grant. Which role has which lives in the
+// role_permissions table.
+const (
+	permChirpsModerate = "chirps:moderate"
+	permMetricsRead    = "metrics:read"
+	permRolesManage    = "roles:manage"
+	permUsersManage    = "users:manage"
+	permWebhooksManage = "webhooks:manage"
+)
+
+// hasPermission reports whether one of the caller's roles grants perm. The
+// roles come from the access token, so a role granted since the caller
+// logged in only counts after their next refresh.
+func (cfg *apiConfig) hasPermission(ctx context.Context, p principal, perm string) (bool, error) {
+	if len(p.Roles) == 0 {
+		return false, nil
+	}
+	perms, err := cfg.db.ListRolePermissions(ctx, p.Roles)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(perms, perm), nil
+}
+
+// requirePermission lets a request through only from a login whose roles
+// grant perm. Personal access tokens never carry roles.
+func (cfg *apiConfig) requirePermission(perm string, next http.HandlerFunc) http.HandlerFunc {
+	return cfg.requireLogin(func(w http.ResponseWriter, req *http.Request) {
+		caller := callerOf(req)
+		allowed, err := cfg.hasPermission(req.Context(), caller, perm)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check permissions", err)
+			return
+		}
+		if !allowed {
+			log.Printf("User %s was refused %s on %s %s", caller.UserID, perm, req.Method, req.URL.Path)
+			respondWithError(w, http.StatusForbidden, "You don't have permission to do that", nil)
+			return
+		}
+		next(w, req)
+	})
+}
@@ -0,0 +1,36 @@
+-- name: RevokeAccessToken :one
+INSERT INTO access_token_revocations(id, created_at, user_id, token_id, expires_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+--
+
+-- name: BumpUserTokenVersion :one
+WITH bumped AS (
+    UPDATE users
+    SET token_version = token_version + 1
+    WHERE users.id = $1
+    RETURNING users.id, users.token_version
+)
+INSERT INTO access_token_revocations(id, created_at, user_id, min_version, expires_at)
+SELECT gen_random_uuid(), NOW(), bumped.id, bumped.token_version, $2
+FROM bumped
+RETURNING *;
+--
+
+-- name: ListAccessTokenRevocationsSince :many
+SELECT * FROM access_token_revocations
+WHERE created_at >= $1
+AND expires_at > NOW()
+ORDER BY created_at;
+--
+
+-- name: DeleteExpiredAccessTokenRevocations :execrows
+DELETE FROM access_token_revocations
+WHERE expires_at <= NOW();
+--
@@ -1,11 +1,12 @@
 -- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, parent_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 --
@@ -15,12 +16,217 @@ SELECT * FROM chirps
 ORDER BY created_at ASC;
 --
 
+-- name: GetChirpsByAuthor :many
+SELECT * FROM chirps
+WHERE user_id = $1
+ORDER BY created_at ASC;
+--
+
 -- name: GetChirp :one
 SELECT * FROM chirps 
 WHERE id = $1;
 --
 
+-- name: GetChirpForUpdate :one
+SELECT * FROM chirps
+WHERE id = $1
+FOR UPDATE;
+--
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW(), edited_at = NOW()
+WHERE id = $1
+RETURNING *;
+--
+
 -- name: DeleteChirp :exec
 DELETE FROM chirps
 WHERE id = $1;
 --
+
+-- name: TombstoneChirp :exec
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+--
+
+-- name: IncrementReplyCount :exec
+UPDATE chirps
+SET reply_count = reply_count + 1
+WHERE id = $1;
+--
+
+-- name: DecrementReplyCount :exec
+UPDATE chirps
+SET reply_count = reply_count - 1
+WHERE id = $1;
+--
+
+-- name: IncrementLikeCount :exec
+UPDATE chirps
+SET like_count = like_count + 1
+WHERE id = $1;
+--
+
+-- name: DecrementLikeCount :exec
+UPDATE chirps
+SET like_count = like_count - 1
+WHERE id = $1;
+--
+
+-- name: IncrementRechirpCount :exec
+UPDATE chirps
+SET rechirp_count = rechirp_count + 1
+WHERE id = $1;
+--
+
+-- name: DecrementRechirpCount :exec
+UPDATE chirps
+SET rechirp_count = rechirp_count - 1
+WHERE id = $1;
+--
+
+-- name: CreateRechirp :one
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, rechirp_of_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    '',
+    $1,
+    $2
+)
+ON CONFLICT (user_id, rechirp_of_id) WHERE rechirp_of_id IS NOT NULL DO NOTHING
+RETURNING *;
+--
+
+-- name: GetRechirp :one
+SELECT * FROM chirps
+WHERE user_id = $1
+AND rechirp_of_id = $2;
+--
+
+-- name: DeleteRechirp :execrows
+DELETE FROM chirps
+WHERE user_id = $1
+AND rechirp_of_id = $2;
+--
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+--
+
+-- name: ListChirpsAsc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until'))
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until'))
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: SearchChirps :many
+SELECT sqlc.embed(chirps),
+    ts_rank_cd(search_vector, to_tsquery('english', sqlc.arg('query')))::real AS rank,
+    ts_headline('english', body, to_tsquery('english', sqlc.arg('query')),
+        'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) || ', MaxFragments=2, MaxWords=20, MinWords=5')::text AS headline
+FROM chirps
+WHERE search_vector @@ to_tsquery('english', sqlc.arg('query'))
+AND deleted_at IS NULL
+AND rechirp_of_id IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until'))
+AND (sqlc.narg('cursor_rank')::real IS NULL
+    OR (ts_rank_cd(search_vector, to_tsquery('english', sqlc.arg('query')))::real, created_at, id)
+        < (sqlc.narg('cursor_rank'), sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: SearchChirpsReverse :many
+SELECT sqlc.embed(chirps),
+    ts_rank_cd(search_vector, to_tsquery('english', sqlc.arg('query')))::real AS rank,
+    ts_headline('english', body, to_tsquery('english', sqlc.arg('query')),
+        'StartSel=' || chr(57344) || ', StopSel=' || chr(57345) || ', MaxFragments=2, MaxWords=20, MinWords=5')::text AS headline
+FROM chirps
+WHERE search_vector @@ to_tsquery('english', sqlc.arg('query'))
+AND deleted_at IS NULL
+AND rechirp_of_id IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until'))
+AND (sqlc.narg('cursor_rank')::real IS NULL
+    OR (ts_rank_cd(search_vector, to_tsquery('english', sqlc.arg('query')))::real, created_at, id)
+        > (sqlc.narg('cursor_rank'), sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid))
+ORDER BY rank ASC, created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT chirps.*, 1 AS depth FROM chirps
+    WHERE chirps.id = (SELECT parent_id FROM chirps AS child WHERE child.id = $1)
+    UNION ALL
+    SELECT chirps.*, ancestors.depth + 1 FROM chirps
+    JOIN ancestors ON chirps.id = ancestors.parent_id
+)
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM ancestors
+ORDER BY depth DESC;
+--
+
+-- name: ListChirpReplies :many
+SELECT * FROM chirps
+WHERE parent_id = sqlc.arg('parent_id')
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: ListChirpRepliesReverse :many
+SELECT * FROM chirps
+WHERE parent_id = sqlc.arg('parent_id')
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: ListChirpDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT chirps.*, 1 AS depth FROM chirps
+    WHERE chirps.parent_id = ANY(sqlc.arg('parent_ids')::uuid[])
+    UNION ALL
+    SELECT chirps.*, descendants.depth + 1 FROM chirps
+    JOIN descendants ON chirps.parent_id = descendants.id
+    WHERE descendants.depth < sqlc.arg('max_depth')::int
+)
+SELECT id, created_at, updated_at, body, user_id, search_vector, parent_id, reply_count, deleted_at, like_count, rechirp_count, rechirp_of_id, edited_at FROM descendants
+ORDER BY depth ASC, created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: CountChirpsByAuthorSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+AND created_at >= $2
+AND rechirp_of_id IS NULL;
+--
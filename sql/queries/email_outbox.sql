@@ -0,0 +1,33 @@
+-- name: EnqueueEmail :exec
+INSERT INTO email_outbox(id, created_at, to_address, subject, body, next_attempt_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    NOW()
+);
+--
+
+-- name: ClaimPendingEmails :many
+SELECT * FROM email_outbox
+WHERE sent_at IS NULL
+AND next_attempt_at <= NOW()
+AND attempts < $1
+ORDER BY next_attempt_at
+LIMIT $2
+FOR UPDATE SKIP LOCKED;
+--
+
+-- name: MarkEmailSent :exec
+UPDATE email_outbox
+SET sent_at = NOW(), attempts = attempts + 1, last_error = NULL
+WHERE id = $1;
+--
+
+-- name: MarkEmailFailed :exec
+UPDATE email_outbox
+SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+WHERE id = $1;
+--
@@ -0,0 +1,16 @@
+-- name: AddFileserverHits :exec
+UPDATE fileserver_hits
+SET hits = hits + $1
+WHERE id = 1;
+--
+
+-- name: GetFileserverHits :one
+SELECT hits FROM fileserver_hits
+WHERE id = 1;
+--
+
+-- name: ResetFileserverHits :exec
+UPDATE fileserver_hits
+SET hits = 0
+WHERE id = 1;
+--
@@ -0,0 +1,91 @@
+-- name: FollowUser :exec
+INSERT INTO follows(follower_id, followee_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+--
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1
+AND followee_id = $2;
+--
+
+-- name: GetFollowCounts :one
+SELECT
+    (SELECT COUNT(*) FROM follows WHERE followee_id = $1) AS followers,
+    (SELECT COUNT(*) FROM follows WHERE follower_id = $1) AS following;
+--
+
+-- name: ListFollowers :many
+SELECT follower_id AS user_id, created_at AS followed_at FROM follows
+WHERE followee_id = sqlc.arg('user_id')
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until'))
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, follower_id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, follower_id DESC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: ListFollowersReverse :many
+SELECT follower_id AS user_id, created_at AS followed_at FROM follows
+WHERE followee_id = sqlc.arg('user_id')
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until'))
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, follower_id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at ASC, follower_id ASC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: ListFollowing :many
+SELECT followee_id AS user_id, created_at AS followed_at FROM follows
+WHERE follower_id = sqlc.arg('user_id')
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until'))
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, followee_id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, followee_id DESC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: ListFollowingReverse :many
+SELECT followee_id AS user_id, created_at AS followed_at FROM follows
+WHERE follower_id = sqlc.arg('user_id')
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until'))
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, followee_id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at ASC, followee_id ASC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: ListTimeline :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (user_id = sqlc.arg('user_id')
+    OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg('user_id')))
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until'))
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: ListTimelineReverse :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (user_id = sqlc.arg('user_id')
+    OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg('user_id')))
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until'))
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+--
@@ -0,0 +1,33 @@
+-- name: LikeChirp :execrows
+INSERT INTO chirp_likes(user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+--
+
+-- name: UnlikeChirp :execrows
+DELETE FROM chirp_likes
+WHERE user_id = $1
+AND chirp_id = $2;
+--
+
+-- name: ListChirpLikes :many
+SELECT user_id, created_at AS liked_at FROM chirp_likes
+WHERE chirp_id = sqlc.arg('chirp_id')
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, user_id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at DESC, user_id DESC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: ListChirpLikesReverse :many
+SELECT user_id, created_at AS liked_at FROM chirp_likes
+WHERE chirp_id = sqlc.arg('chirp_id')
+AND (sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, user_id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY created_at ASC, user_id ASC
+LIMIT sqlc.arg('limit');
+--
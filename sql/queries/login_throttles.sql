@@ -0,0 +1,38 @@
+-- name: GetLoginThrottles :many
+SELECT * FROM login_throttles
+WHERE key = ANY($1::text[]);
+--
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_throttles(key, failures, last_failure_at, locked_until)
+VALUES (
+    $1,
+    1,
+    NOW(),
+    NOW()
+)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < $2 THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = NOW()
+RETURNING *;
+--
+
+-- name: LockLoginThrottle :exec
+UPDATE login_throttles
+SET locked_until = GREATEST(locked_until, $2)
+WHERE key = $1;
+--
+
+-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE key = $1;
+--
+
+-- name: DeleteStaleLoginThrottles :execrows
+DELETE FROM login_throttles
+WHERE last_failure_at < $1
+AND locked_until < NOW();
+--
@@ -0,0 +1,119 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients(id, owner_id, name, secret_hash, redirect_uris, scopes, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW()
+)
+RETURNING *;
+--
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+--
+
+-- name: ListOAuthClients :many
+SELECT * FROM oauth_clients
+WHERE owner_id = $1
+AND revoked_at IS NULL
+ORDER BY created_at DESC;
+--
+
+-- name: RevokeOAuthClient :execrows
+UPDATE oauth_clients
+SET revoked_at = NOW()
+WHERE id = $1
+AND owner_id = $2
+AND revoked_at IS NULL;
+--
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes(id, code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    NOW(),
+    $7
+);
+--
+
+-- name: GetOAuthAuthorizationCodeForUpdate :one
+SELECT * FROM oauth_authorization_codes
+WHERE code_hash = $1
+FOR UPDATE;
+--
+
+-- name: UseOAuthAuthorizationCode :exec
+UPDATE oauth_authorization_codes
+SET used_at = NOW()
+WHERE id = $1;
+--
+
+-- name: DeleteExpiredOAuthAuthorizationCodes :execrows
+DELETE FROM oauth_authorization_codes
+WHERE expires_at < $1;
+--
+
+-- name: CreateOAuthRefreshToken :one
+INSERT INTO oauth_refresh_tokens(id, token_hash, client_id, user_id, family_id, scopes, created_at, expires_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW(),
+    $6
+)
+RETURNING *;
+--
+
+-- name: GetOAuthRefreshTokenByHash :one
+SELECT * FROM oauth_refresh_tokens
+WHERE token_hash = $1;
+--
+
+-- name: GetOAuthRefreshTokenByHashForUpdate :one
+SELECT * FROM oauth_refresh_tokens
+WHERE token_hash = $1
+FOR UPDATE;
+--
+
+-- name: RevokeOAuthRefreshToken :exec
+UPDATE oauth_refresh_tokens
+SET revoked_at = NOW()
+WHERE id = $1
+AND revoked_at IS NULL;
+--
+
+-- name: RevokeOAuthRefreshTokenFamily :exec
+UPDATE oauth_refresh_tokens
+SET revoked_at = NOW()
+WHERE family_id = $1
+AND revoked_at IS NULL;
+--
+
+-- name: RevokeOAuthClientRefreshTokens :exec
+UPDATE oauth_refresh_tokens
+SET revoked_at = NOW()
+WHERE client_id = $1
+AND revoked_at IS NULL;
+--
+
+-- name: RevokeUserOAuthRefreshTokens :exec
+UPDATE oauth_refresh_tokens
+SET revoked_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL;
+--
@@ -0,0 +1,48 @@
+-- name: CreateOIDCLogin :exec
+INSERT INTO oidc_logins (state_hash, nonce, code_verifier, created_at, expires_at)
+VALUES ($1, $2, $3, NOW(), $4);
+--
+
+-- name: TakeOIDCLogin :one
+DELETE FROM oidc_logins
+WHERE state_hash = $1
+RETURNING *;
+--
+
+-- name: DeleteExpiredOIDCLogins :execrows
+DELETE FROM oidc_logins
+WHERE expires_at < $1;
+--
+
+-- name: CreateOIDCSignIn :exec
+INSERT INTO oidc_sign_ins (code_hash, user_id, created_at, expires_at)
+VALUES ($1, $2, NOW(), $3);
+--
+
+-- name: TakeOIDCSignIn :one
+DELETE FROM oidc_sign_ins
+WHERE code_hash = $1
+RETURNING *;
+--
+
+-- name: DeleteExpiredOIDCSignIns :execrows
+DELETE FROM oidc_sign_ins
+WHERE expires_at < $1;
+--
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE issuer = $1 AND subject = $2;
+--
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (issuer, subject, user_id, email, created_at, last_login_at)
+VALUES ($1, $2, $3, $4, NOW(), NOW())
+RETURNING *;
+--
+
+-- name: TouchUserIdentity :exec
+UPDATE user_identities
+SET email = $3, last_login_at = NOW()
+WHERE issuer = $1 AND subject = $2;
+--
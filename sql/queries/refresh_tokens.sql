@@ -1,12 +1,16 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens(token, created_at, updated_at, user_id, expires_at, revoked_at)
+INSERT INTO refresh_tokens(token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, user_agent, ip_address, last_used_at)
 VALUES (
     $1,
     NOW(),
     NOW(),
     $2,
     NOW() + interval '60 days',
-    NULL
+    NULL,
+    $3,
+    $4,
+    $5,
+    NOW()
 )
 RETURNING *;
 --
@@ -19,8 +23,55 @@ AND NOW() < expires_at
 AND revoked_at IS NULL;
 --
 
--- name: RevokeRefreshToken :exec
+-- name: GetRefreshTokenForUpdate :one
+SELECT * FROM refresh_tokens
+WHERE token = $1
+FOR UPDATE;
+--
+
+-- name: RevokeRefreshToken :one
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE token = $1
+RETURNING *;
+--
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1
+AND revoked_at IS NULL;
+--
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL;
+--
+
+-- name: ListUserSessions :many
+SELECT
+    refresh_tokens.family_id,
+    refresh_tokens.user_agent,
+    refresh_tokens.ip_address,
+    refresh_tokens.last_used_at,
+    refresh_tokens.expires_at,
+    (
+        SELECT MIN(family.created_at) FROM refresh_tokens family
+        WHERE family.family_id = refresh_tokens.family_id
+    )::timestamp AS signed_in_at
+FROM refresh_tokens
+WHERE refresh_tokens.user_id = $1
+AND refresh_tokens.revoked_at IS NULL
+AND NOW() < refresh_tokens.expires_at
+ORDER BY refresh_tokens.last_used_at DESC;
+--
+
+-- name: RevokeUserSession :execrows
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1;
+WHERE user_id = $1
+AND family_id = $2
+AND revoked_at IS NULL;
 --
@@ -0,0 +1,49 @@
+-- name: ListRoles :many
+SELECT * FROM roles
+ORDER BY name;
+--
+
+-- name: GetRole :one
+SELECT * FROM roles
+WHERE name = $1;
+--
+
+-- name: ListUserRoles :many
+SELECT role FROM user_roles
+WHERE user_id = $1
+ORDER BY role;
+--
+
+-- name: ListRolePermissions :many
+SELECT DISTINCT permission FROM role_permissions
+WHERE role = ANY(sqlc.arg('roles')::text[])
+ORDER BY permission;
+--
+
+-- name: GrantUserRole :execrows
+INSERT INTO user_roles(user_id, role, granted_at, granted_by)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    $3
+)
+ON CONFLICT DO NOTHING;
+--
+
+-- name: RevokeUserRole :execrows
+DELETE FROM user_roles
+WHERE user_id = $1
+AND role = $2;
+--
+
+-- name: CountUsersWithRole :one
+SELECT COUNT(*) FROM user_roles
+WHERE role = $1;
+--
+
+-- name: LockRole :exec
+SELECT name FROM roles
+WHERE name = $1
+FOR UPDATE;
+--
@@ -0,0 +1,60 @@
+-- name: GetSubscriptionByUser :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+--
+
+-- name: GetSubscriptionByUserForUpdate :one
+SELECT * FROM subscriptions
+WHERE user_id = $1
+FOR UPDATE;
+--
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions(id, created_at, updated_at, user_id, plan, status, current_period_end, grace_period_end, last_event_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = EXCLUDED.status,
+    current_period_end = EXCLUDED.current_period_end,
+    grace_period_end = EXCLUDED.grace_period_end,
+    last_event_at = EXCLUDED.last_event_at,
+    updated_at = NOW()
+RETURNING *;
+--
+
+-- name: ExpireLapsedSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', updated_at = NOW()
+WHERE status <> 'expired'
+AND COALESCE(grace_period_end, current_period_end) <= NOW()
+RETURNING *;
+--
+
+-- name: CreateSubscriptionEvent :exec
+INSERT INTO subscription_events(id, subscription_id, created_at, event_type, status, period_end, webhook_event_id)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5
+);
+--
+
+-- name: ListSubscriptionEvents :many
+SELECT * FROM subscription_events
+WHERE subscription_id = $1
+ORDER BY created_at DESC;
+--
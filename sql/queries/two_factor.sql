@@ -0,0 +1,99 @@
+-- name: StartTOTPEnrollment :execrows
+INSERT INTO user_totp(user_id, secret, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    created_at = EXCLUDED.created_at,
+    last_used_step = 0
+WHERE user_totp.confirmed_at IS NULL;
+--
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp
+WHERE user_id = $1;
+--
+
+-- name: GetUserTOTPForUpdate :one
+SELECT * FROM user_totp
+WHERE user_id = $1
+FOR UPDATE;
+--
+
+-- name: ConfirmUserTOTP :exec
+UPDATE user_totp
+SET confirmed_at = NOW(),
+    last_used_step = $2
+WHERE user_id = $1;
+--
+
+-- name: SetTOTPLastUsedStep :exec
+UPDATE user_totp
+SET last_used_step = $2
+WHERE user_id = $1;
+--
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+WHERE user_id = $1;
+--
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes(id, user_id, code_hash, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    NOW()
+);
+--
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
+--
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1
+AND code_hash = $2
+AND used_at IS NULL;
+--
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM recovery_codes
+WHERE user_id = $1
+AND used_at IS NULL;
+--
+
+-- name: CreateLoginChallenge :exec
+INSERT INTO login_challenges(token_hash, user_id, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    $3
+);
+--
+
+-- name: GetLoginChallengeForUpdate :one
+SELECT * FROM login_challenges
+WHERE token_hash = $1
+FOR UPDATE;
+--
+
+-- name: FailLoginChallenge :exec
+UPDATE login_challenges
+SET attempts = attempts + 1
+WHERE token_hash = $1;
+--
+
+-- name: UseLoginChallenge :exec
+UPDATE login_challenges
+SET used_at = NOW()
+WHERE token_hash = $1;
+--
@@ -16,14 +16,40 @@ SELECT * FROM users WHERE email = $1;
 
 -- name: UpdateUser :one
 UPDATE users
-SET email = $1, hashed_password = $2, updated_at = NOW()
+SET hashed_password = $1, pending_email = COALESCE($2, pending_email), updated_at = NOW()
 WHERE id = $3
 RETURNING *;
 --
 
--- name: UpgradeUser :one
+-- name: SetUserChirpyRedUntil :one
 UPDATE users
-SET is_chirpy_red = true, updated_at = NOW()
+SET chirpy_red_until = sqlc.narg('chirpy_red_until'),
+    is_chirpy_red = COALESCE(sqlc.narg('chirpy_red_until')::timestamp > NOW(), false),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id')
+RETURNING *;
+--
+
+-- name: GetUser :one
+SELECT * FROM users WHERE id = $1;
+--
+
+-- name: GetUserPlan :one
+SELECT users.chirpy_red_until, subscriptions.plan
+FROM users
+LEFT JOIN subscriptions ON subscriptions.user_id = users.id
+WHERE users.id = $1;
+--
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1;
+--
+
+-- name: ConfirmUserEmail :one
+UPDATE users
+SET email = $2, pending_email = NULL, email_verified_at = NOW(), updated_at = NOW()
 WHERE id = $1
 RETURNING *;
 --
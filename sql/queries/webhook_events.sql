@@ -0,0 +1,50 @@
+-- name: RecordWebhookEvent :exec
+INSERT INTO webhook_events(id, event_id, event_type, payload, received_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT (event_id) DO NOTHING;
+--
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+--
+
+-- name: GetWebhookEventByEventIDForUpdate :one
+SELECT * FROM webhook_events
+WHERE event_id = $1
+FOR UPDATE;
+--
+
+-- name: MarkWebhookEventProcessed :exec
+UPDATE webhook_events
+SET processed_at = NOW(), attempts = attempts + 1, last_error = NULL
+WHERE id = $1;
+--
+
+-- name: MarkWebhookEventFailed :exec
+UPDATE webhook_events
+SET attempts = attempts + 1, last_error = $2
+WHERE id = $1;
+--
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg('cursor_received_at')::timestamp IS NULL
+    OR (received_at, id) < (sqlc.narg('cursor_received_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY received_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+--
+
+-- name: ListWebhookEventsReverse :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg('cursor_received_at')::timestamp IS NULL
+    OR (received_at, id) > (sqlc.narg('cursor_received_at'), sqlc.narg('cursor_id')::uuid))
+ORDER BY received_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+--
@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE chirp_likes(
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id   UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX chirp_likes_chirp_id_created_at_idx ON chirp_likes(chirp_id, created_at, user_id);
+
+ALTER TABLE chirps
+ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN rechirp_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN rechirp_of_id UUID REFERENCES chirps(id) ON DELETE CASCADE;
+
+CREATE UNIQUE INDEX chirps_user_id_rechirp_of_id_idx ON chirps(user_id, rechirp_of_id)
+WHERE rechirp_of_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_user_id_rechirp_of_id_idx;
+
+ALTER TABLE chirps
+DROP COLUMN rechirp_of_id,
+DROP COLUMN rechirp_count,
+DROP COLUMN like_count;
+
+DROP TABLE chirp_likes;
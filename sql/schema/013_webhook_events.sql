@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE webhook_events(
+    id           UUID PRIMARY KEY,
+    event_id     TEXT NOT NULL UNIQUE,
+    event_type   TEXT NOT NULL,
+    payload      JSONB NOT NULL,
+    received_at  TIMESTAMP NOT NULL,
+    processed_at TIMESTAMP,
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    last_error   TEXT
+);
+
+CREATE INDEX webhook_events_received_at_id_idx ON webhook_events(received_at, id);
+
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,48 @@
+-- +goose Up
+CREATE TABLE subscriptions(
+    id                 UUID PRIMARY KEY,
+    created_at         TIMESTAMP NOT NULL,
+    updated_at         TIMESTAMP NOT NULL,
+    user_id            UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    plan               TEXT NOT NULL,
+    status             TEXT NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    grace_period_end   TIMESTAMP
+);
+
+CREATE INDEX subscriptions_lapse_idx ON subscriptions(COALESCE(grace_period_end, current_period_end))
+WHERE status <> 'expired';
+
+CREATE TABLE subscription_events(
+    id               UUID PRIMARY KEY,
+    subscription_id  UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    created_at       TIMESTAMP NOT NULL,
+    event_type       TEXT NOT NULL,
+    status           TEXT NOT NULL,
+    period_end       TIMESTAMP NOT NULL,
+    webhook_event_id UUID REFERENCES webhook_events(id) ON DELETE SET NULL
+);
+
+CREATE INDEX subscription_events_subscription_id_idx ON subscription_events(subscription_id, created_at);
+
+ALTER TABLE users
+ADD COLUMN chirpy_red_until TIMESTAMP;
+
+-- Members from before subscriptions were tracked keep Red until they are
+-- billed, downgraded or miss a payment through Polka, which replaces this
+-- open period with a real one.
+INSERT INTO subscriptions(id, created_at, updated_at, user_id, plan, status, current_period_end)
+SELECT gen_random_uuid(), NOW(), NOW(), id, 'red', 'active', '9999-12-31'
+FROM users
+WHERE is_chirpy_red;
+
+UPDATE users
+SET chirpy_red_until = '9999-12-31'
+WHERE is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN chirpy_red_until;
+
+DROP TABLE subscription_events;
+DROP TABLE subscriptions;
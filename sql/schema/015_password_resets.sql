@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE password_reset_tokens(
+    token_hash TEXT PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at    TIMESTAMP
+);
+
+CREATE INDEX password_reset_tokens_user_id_idx ON password_reset_tokens(user_id);
+
+CREATE TABLE email_outbox(
+    id              UUID PRIMARY KEY,
+    created_at      TIMESTAMP NOT NULL,
+    to_address      TEXT NOT NULL,
+    subject         TEXT NOT NULL,
+    body            TEXT NOT NULL,
+    sent_at         TIMESTAMP,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    next_attempt_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX email_outbox_pending_idx ON email_outbox(next_attempt_at)
+WHERE sent_at IS NULL;
+
+-- +goose Down
+DROP TABLE email_outbox;
+DROP TABLE password_reset_tokens;
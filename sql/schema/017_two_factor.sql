@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE user_totp(
+    user_id        UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret         TEXT NOT NULL,
+    created_at     TIMESTAMP NOT NULL,
+    confirmed_at   TIMESTAMP,
+    last_used_step BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE recovery_codes(
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash  TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    used_at    TIMESTAMP,
+    UNIQUE(user_id, code_hash)
+);
+
+CREATE TABLE login_challenges(
+    token_hash TEXT PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    attempts   INTEGER NOT NULL DEFAULT 0,
+    used_at    TIMESTAMP
+);
+
+CREATE INDEX login_challenges_user_id_idx ON login_challenges(user_id);
+
+-- +goose Down
+DROP TABLE login_challenges;
+DROP TABLE recovery_codes;
+DROP TABLE user_totp;
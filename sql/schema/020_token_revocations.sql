@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0;
+
+-- Each row revokes either one access token (token_id) or every token of the
+-- user issued below min_version. Rows are only needed until the tokens they
+-- cover have expired.
+CREATE TABLE access_token_revocations(
+    id          UUID PRIMARY KEY,
+    created_at  TIMESTAMP NOT NULL,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_id    UUID,
+    min_version INTEGER,
+    expires_at  TIMESTAMP NOT NULL,
+    CHECK ((token_id IS NULL) <> (min_version IS NULL))
+);
+
+CREATE INDEX access_token_revocations_created_at_idx ON access_token_revocations(created_at);
+CREATE INDEX access_token_revocations_expires_at_idx ON access_token_revocations(expires_at);
+
+-- +goose Down
+DROP TABLE access_token_revocations;
+
+ALTER TABLE users
+DROP COLUMN token_version;
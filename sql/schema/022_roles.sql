@@ -0,0 +1,38 @@
+-- +goose Up
+CREATE TABLE roles(
+    name        TEXT PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+CREATE TABLE role_permissions(
+    role       TEXT NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE user_roles(
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role       TEXT NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    granted_at TIMESTAMP NOT NULL,
+    granted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    PRIMARY KEY (user_id, role)
+);
+
+CREATE INDEX user_roles_role_idx ON user_roles(role);
+
+INSERT INTO roles(name, description) VALUES
+    ('admin', 'Runs the service and decides who else gets a role'),
+    ('moderator', 'Removes chirps that break the rules');
+
+INSERT INTO role_permissions(role, permission) VALUES
+    ('admin', 'chirps:moderate'),
+    ('admin', 'metrics:read'),
+    ('admin', 'roles:manage'),
+    ('admin', 'users:manage'),
+    ('admin', 'webhooks:manage'),
+    ('moderator', 'chirps:moderate');
+
+-- +goose Down
+DROP TABLE user_roles;
+DROP TABLE role_permissions;
+DROP TABLE roles;
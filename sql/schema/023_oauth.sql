@@ -0,0 +1,51 @@
+-- +goose Up
+-- A client without a secret_hash is public, like a mobile or single-page
+-- app, and can only use PKCE to prove who it is.
+CREATE TABLE oauth_clients(
+    id            UUID PRIMARY KEY,
+    owner_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name          TEXT NOT NULL,
+    secret_hash   TEXT,
+    redirect_uris TEXT[] NOT NULL,
+    scopes        TEXT[] NOT NULL,
+    created_at    TIMESTAMP NOT NULL,
+    revoked_at    TIMESTAMP
+);
+
+CREATE INDEX oauth_clients_owner_id_idx ON oauth_clients(owner_id);
+
+CREATE TABLE oauth_authorization_codes(
+    id             UUID PRIMARY KEY,
+    code_hash      TEXT NOT NULL UNIQUE,
+    client_id      UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id        UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri   TEXT NOT NULL,
+    scopes         TEXT[] NOT NULL,
+    code_challenge TEXT NOT NULL,
+    created_at     TIMESTAMP NOT NULL,
+    expires_at     TIMESTAMP NOT NULL,
+    used_at        TIMESTAMP
+);
+
+-- family_id is the authorization code a chain of refresh tokens started
+-- from, so replaying the code or a rotated token can revoke the chain.
+CREATE TABLE oauth_refresh_tokens(
+    id         UUID PRIMARY KEY,
+    token_hash TEXT NOT NULL UNIQUE,
+    client_id  UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family_id  UUID NOT NULL,
+    scopes     TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX oauth_refresh_tokens_family_id_idx ON oauth_refresh_tokens(family_id);
+CREATE INDEX oauth_refresh_tokens_user_id_idx ON oauth_refresh_tokens(user_id);
+CREATE INDEX oauth_refresh_tokens_client_id_idx ON oauth_refresh_tokens(client_id);
+
+-- +goose Down
+DROP TABLE oauth_refresh_tokens;
+DROP TABLE oauth_authorization_codes;
+DROP TABLE oauth_clients;
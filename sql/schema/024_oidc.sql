@@ -0,0 +1,29 @@
+-- +goose Up
+-- An identity is an account at an external OpenID provider, named by the
+-- provider's issuer and its subject for the user. Emails change, the
+-- subject doesn't.
+CREATE TABLE user_identities(
+    issuer        TEXT NOT NULL,
+    subject       TEXT NOT NULL,
+    user_id       UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email         TEXT NOT NULL,
+    created_at    TIMESTAMP NOT NULL,
+    last_login_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX user_identities_user_id_idx ON user_identities(user_id);
+
+-- A sign-in in progress, between sending the user to the provider and
+-- their return. The state is only stored hashed.
+CREATE TABLE oidc_logins(
+    state_hash    TEXT PRIMARY KEY,
+    nonce         TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    created_at    TIMESTAMP NOT NULL,
+    expires_at    TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE oidc_logins;
+DROP TABLE user_identities;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Numbers the events relayed between instances, so an ID handed to a
+-- client by one instance means the same to every other.
+CREATE SEQUENCE event_ids;
+
+-- Static file hits are counted by every instance and added up here.
+CREATE TABLE fileserver_hits(
+    id   INTEGER PRIMARY KEY CHECK (id = 1),
+    hits BIGINT NOT NULL
+);
+
+INSERT INTO fileserver_hits(id, hits) VALUES (1, 0);
+
+-- +goose Down
+DROP TABLE fileserver_hits;
+DROP SEQUENCE event_ids;
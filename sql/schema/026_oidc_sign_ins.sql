@@ -0,0 +1,12 @@
+-- +goose Up
+-- A finished sign-in waiting to be traded for tokens by the client the
+-- browser was sent back to. The code is only stored hashed and works once.
+CREATE TABLE oidc_sign_ins(
+    code_hash  TEXT PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE oidc_sign_ins;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- When the newest event applied to a subscription happened, by Polka's
+-- clock. Deliveries can overtake each other, and an event older than this
+-- mustn't undo what a newer one did. Rows from before are NULL and accept
+-- anything.
+ALTER TABLE subscriptions
+ADD COLUMN last_event_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE subscriptions
+DROP COLUMN last_event_at;
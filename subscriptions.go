@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/polka"
+)
+
+const (
+	subscriptionActive   = "active"
+	subscriptionPastDue  = "past_due"
+	subscriptionCanceled = "canceled"
+	subscriptionExpired  = "expired"
+
+	defaultPlan = "red"
+	// subscriptionPeriod is assumed when Polka doesn't send a period end.
+	subscriptionPeriod = 30 * 24 * time.Hour
+	// gracePeriod keeps a member on Red after a failed payment while Polka
+	// retries the charge.
+	gracePeriod = 7 * 24 * time.Hour
+
+	subscriptionSweepInterval = time.Minute
+)
+
+// legacyPeriodEnd is the open-ended period members from before
+// subscriptions were tracked were given. They never paid for a period, so
+// nothing is left of it once they cancel or stop paying.
+var legacyPeriodEnd = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// paidThrough is when what the member paid for runs out.
+func paidThrough(sub database.Subscription, now time.Time) time.Time {
+	if !sub.CurrentPeriodEnd.Before(legacyPeriodEnd) {
+		return now
+	}
+	return sub.CurrentPeriodEnd
+}
+
+// membershipEnd is when a subscription stops granting Red.
+func membershipEnd(sub database.Subscription) time.Time {
+	if sub.GracePeriodEnd.Valid {
+		return sub.GracePeriodEnd.Time
+	}
+	return sub.CurrentPeriodEnd
+}
+
+// applySubscriptionEvent moves a user's subscription through its lifecycle
+// and records the transition in its history. Periods only ever move forward,
+// so an upgrade or renewal delivered late can't shorten a membership.
+//
+// Deliveries can overtake each other, so events are ordered by when they
+// happened at Polka. One older than the last applied can still add to the
+// paid period, but can't change the status: a late upgrade mustn't revive a
+// subscription that was cancelled after it. A cancellation or failed payment
+// for a subscription we haven't seen yet is recorded for the same reason.
+func applySubscriptionEvent(ctx context.Context, q *database.Queries, webhookID uuid.UUID, event polka.Event, now time.Time) (*database.User, error) {
+	_, err := q.GetUser(ctx, event.Data.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errPolkaUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := q.GetSubscriptionByUserForUpdate(ctx, event.Data.UserID)
+	exists := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	happenedAt := now
+	if event.CreatedAt != nil {
+		happenedAt = event.CreatedAt.UTC()
+	}
+	stale := exists && current.LastEventAt.Valid && happenedAt.Before(current.LastEventAt.Time)
+
+	next := database.UpsertSubscriptionParams{
+		UserID:           event.Data.UserID,
+		Plan:             current.Plan,
+		Status:           current.Status,
+		CurrentPeriodEnd: current.CurrentPeriodEnd,
+		GracePeriodEnd:   current.GracePeriodEnd,
+		LastEventAt:      sql.NullTime{Time: happenedAt, Valid: true},
+	}
+	if stale {
+		next.LastEventAt = current.LastEventAt
+	}
+	if event.Data.Plan != "" && !stale {
+		next.Plan = event.Data.Plan
+	}
+	if next.Plan == "" {
+		next.Plan = defaultPlan
+	}
+
+	switch event.Event {
+	case polka.EventUserUpgraded, polka.EventSubscriptionRenewed:
+		paid := paidThrough(current, now)
+		periodEnd := now.Add(subscriptionPeriod)
+		if event.Data.PeriodEnd != nil {
+			periodEnd = event.Data.PeriodEnd.UTC()
+		} else if exists && event.Event == polka.EventSubscriptionRenewed && paid.After(now) {
+			periodEnd = paid.Add(subscriptionPeriod)
+		}
+		if exists && current.Status != subscriptionExpired && paid.After(periodEnd) {
+			periodEnd = paid
+		}
+		if stale {
+			// It was paid for before whatever we applied last, so it only
+			// adds to the period, and to the grace that follows it.
+			if current.Status == subscriptionExpired {
+				return nil, nil
+			}
+			next.CurrentPeriodEnd = later(current.CurrentPeriodEnd, periodEnd)
+			if current.GracePeriodEnd.Valid {
+				next.GracePeriodEnd = sql.NullTime{Time: later(current.GracePeriodEnd.Time, next.CurrentPeriodEnd.Add(gracePeriod)), Valid: true}
+			}
+			break
+		}
+		next.Status = subscriptionActive
+		next.CurrentPeriodEnd = periodEnd
+		next.GracePeriodEnd = sql.NullTime{}
+	case polka.EventPaymentFailed:
+		if stale || current.Status == subscriptionExpired || current.Status == subscriptionCanceled {
+			return nil, nil
+		}
+		if !exists {
+			// The upgrade it follows is still on its way and will add the
+			// period it paid for.
+			next.Status = subscriptionPastDue
+			next.CurrentPeriodEnd = now
+			next.GracePeriodEnd = sql.NullTime{Time: now.Add(gracePeriod), Valid: true}
+		} else if current.Status != subscriptionPastDue {
+			next.Status = subscriptionPastDue
+			next.CurrentPeriodEnd = paidThrough(current, now)
+			next.GracePeriodEnd = sql.NullTime{Time: later(now, next.CurrentPeriodEnd).Add(gracePeriod), Valid: true}
+		}
+	case polka.EventUserDowngraded:
+		if stale || current.Status == subscriptionExpired {
+			return nil, nil
+		}
+		// Cancelling stops renewals; what was already paid for is kept.
+		next.Status = subscriptionCanceled
+		next.CurrentPeriodEnd = paidThrough(current, now)
+		if !exists {
+			next.CurrentPeriodEnd = now
+		}
+		next.GracePeriodEnd = sql.NullTime{}
+	default:
+		return nil, nil
+	}
+
+	sub, err := q.UpsertSubscription(ctx, next)
+	if err != nil {
+		return nil, err
+	}
+
+	err = q.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+		SubscriptionID: sub.ID,
+		EventType:      event.Event,
+		Status:         sub.Status,
+		PeriodEnd:      membershipEnd(sub),
+		WebhookEventID: uuid.NullUUID{UUID: webhookID, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := q.SetUserChirpyRedUntil(ctx, database.SetUserChirpyRedUntilParams{
+		ChirpyRedUntil: sql.NullTime{Time: membershipEnd(sub), Valid: true},
+		ID:             sub.UserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// runSubscriptionSweeper expires lapsed memberships until ctx is cancelled.
+// Each lapsed row is claimed by a single UPDATE, so running it on every
+// instance is safe.
+func (cfg *apiConfig) runSubscriptionSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := cfg.expireLapsedSubscriptions(ctx)
+			if err != nil {
+				log.Printf("Couldn't expire lapsed subscriptions: %s", err)
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) expireLapsedSubscriptions(ctx context.Context) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	lapsed, err := qtx.ExpireLapsedSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	users := []database.User{}
+	for _, sub := range lapsed {
+		err = qtx.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+			SubscriptionID: sub.ID,
+			EventType:      "subscription.expired",
+			Status:         sub.Status,
+			PeriodEnd:      membershipEnd(sub),
+		})
+		if err != nil {
+			return err
+		}
+
+		user, err := qtx.SetUserChirpyRedUntil(ctx, database.SetUserChirpyRedUntilParams{
+			ChirpyRedUntil: sql.NullTime{Time: membershipEnd(sub), Valid: true},
+			ID:             sub.UserID,
+		})
+		if err != nil {
+			return err
+		}
+		users = append(users, user)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		cfg.publish(eventUserUpdated, user.ID, databaseUserToUser(user))
+	}
+	return nil
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
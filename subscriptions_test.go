@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/polka"
+)
+
+// TestSubscriptionEventsOutOfOrder delivers each scenario in order to one
+// user and backwards to another, the worst -shuffle can do, and expects
+// both to end up with the same membership.
+func TestSubscriptionEventsOutOfOrder(t *testing.T) {
+	cfg := newTestConfig(t)
+	ctx := context.Background()
+
+	deliver := func(t *testing.T, events []polka.Event) database.Subscription {
+		t.Helper()
+		for _, event := range events {
+			payload, err := json.Marshal(event)
+			if err != nil {
+				t.Fatalf("couldn't encode %s: %v", event.Event, err)
+			}
+			err = cfg.db.RecordWebhookEvent(ctx, database.RecordWebhookEventParams{
+				EventID:   event.ID,
+				EventType: event.Event,
+				Payload:   payload,
+			})
+			if err != nil {
+				t.Fatalf("couldn't record %s: %v", event.Event, err)
+			}
+			t.Cleanup(func() {
+				cfg.dbConn.Exec("DELETE FROM webhook_events WHERE event_id = $1", event.ID)
+			})
+
+			err = cfg.processWebhookEvent(ctx, event.ID)
+			if err != nil {
+				t.Fatalf("couldn't apply %s: %v", event.Event, err)
+			}
+		}
+
+		sub, err := cfg.db.GetSubscriptionByUser(ctx, events[0].Data.UserID)
+		if err != nil {
+			t.Fatalf("couldn't get the subscription: %v", err)
+		}
+		return sub
+	}
+
+	for _, name := range polka.ScenarioNames() {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now().Truncate(time.Second)
+
+			inOrder := createTestUser(t, cfg)
+			events, err := polka.Scenario(name, inOrder.ID, "red", start)
+			if err != nil {
+				t.Fatalf("couldn't build the scenario: %v", err)
+			}
+			want := deliver(t, events)
+
+			backwards := createTestUser(t, cfg)
+			events, err = polka.Scenario(name, backwards.ID, "red", start)
+			if err != nil {
+				t.Fatalf("couldn't build the scenario: %v", err)
+			}
+			slices.Reverse(events)
+			got := deliver(t, events)
+
+			if got.Status != want.Status {
+				t.Errorf("status = %s, want %s", got.Status, want.Status)
+			}
+			if !got.CurrentPeriodEnd.Equal(want.CurrentPeriodEnd) {
+				t.Errorf("period end = %v, want %v", got.CurrentPeriodEnd, want.CurrentPeriodEnd)
+			}
+			if !membershipEnd(got).Equal(membershipEnd(want)) {
+				t.Errorf("membership ends %v, want %v", membershipEnd(got), membershipEnd(want))
+			}
+		})
+	}
+
+	// The case -shuffle found: cancelled before the upgrade arrived.
+	t.Run("late upgrade after cancelling", func(t *testing.T) {
+		user := createTestUser(t, cfg)
+		events, err := polka.Scenario("churn", user.ID, "red", time.Now().Truncate(time.Second))
+		if err != nil {
+			t.Fatalf("couldn't build the scenario: %v", err)
+		}
+		upgrade := events[0]
+		slices.Reverse(events)
+
+		sub := deliver(t, events)
+		if sub.Status != subscriptionCanceled {
+			t.Fatalf("status = %s, want %s", sub.Status, subscriptionCanceled)
+		}
+		if !sub.CurrentPeriodEnd.Equal(*upgrade.Data.PeriodEnd) {
+			t.Fatalf("period end = %v, want the upgrade's %v", sub.CurrentPeriodEnd, upgrade.Data.PeriodEnd)
+		}
+	})
+}
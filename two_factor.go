@@ -0,0 +1,571 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rangaroo/chirpy-http-server/internal/auth"
+	"github.com/rangaroo/chirpy-http-server/internal/database"
+	"github.com/rangaroo/chirpy-http-server/internal/qr"
+	"github.com/rangaroo/chirpy-http-server/internal/totp"
+)
+
+const (
+	totpIssuer = "Chirpy"
+	// loginChallengeTTL is how long a user has to enter their code after
+	// the password was accepted.
+	loginChallengeTTL    = 5 * time.Minute
+	maxChallengeAttempts = 5
+	recoveryCodeCount    = 10
+)
+
+var errSecondFactorInvalid = errors.New("invalid one-time code")
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type loginChallenge struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+// hasTwoFactor reports whether the user finished enrolling an
+// authenticator.
+func (cfg *apiConfig) hasTwoFactor(ctx context.Context, userID uuid.UUID) (bool, error) {
+	secret, err := cfg.db.GetUserTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return secret.ConfirmedAt.Valid, nil
+}
+
+// startLoginChallenge is handed out instead of tokens when the password was
+// right but a second factor is still needed. Only its hash is stored, and it
+// is useless anywhere but POST /api/login/2fa.
+func (cfg *apiConfig) startLoginChallenge(ctx context.Context, userID uuid.UUID) (loginChallenge, error) {
+	token, err := auth.MakeToken()
+	if err != nil {
+		return loginChallenge{}, err
+	}
+
+	expiresAt := time.Now().UTC().Add(loginChallengeTTL)
+	err = cfg.db.CreateLoginChallenge(ctx, database.CreateLoginChallengeParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return loginChallenge{}, err
+	}
+
+	return loginChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         expiresAt,
+	}, nil
+}
+
+// checkSecondFactor accepts either a code from the authenticator or an
+// unused recovery code. A TOTP code is refused if its step isn't newer than
+// the last one accepted, so an observed code can't be replayed within its
+// window. Both are spent inside q's transaction.
+func checkSecondFactor(ctx context.Context, q *database.Queries, userID uuid.UUID, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		used, err := q.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(normalizeRecoveryCode(recoveryCode)),
+		})
+		if err != nil {
+			return err
+		}
+		if used == 0 {
+			return errSecondFactorInvalid
+		}
+		return nil
+	}
+
+	secret, err := q.GetUserTOTPForUpdate(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if errors.Is(err, sql.ErrNoRows) || !secret.ConfirmedAt.Valid {
+		return errSecondFactorInvalid
+	}
+
+	step, ok := totp.Validate(secret.Secret, code, time.Now())
+	if !ok || step <= secret.LastUsedStep {
+		return errSecondFactorInvalid
+	}
+
+	return q.SetTOTPLastUsedStep(ctx, database.SetTOTPLastUsedStepParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+}
+
+// replaceRecoveryCodes invalidates any earlier set and returns the new codes
+// in the clear. This is the only time they are shown.
+func replaceRecoveryCodes(ctx context.Context, q *database.Queries, userID uuid.UUID) ([]string, error) {
+	err := q.DeleteRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 10)
+		_, err := rand.Read(raw)
+		if err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))
+		code = code[:8] + "-" + code[8:]
+
+		err = q.CreateRecoveryCode(ctx, database.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(normalizeRecoveryCode(code)),
+		})
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode lets users type codes without the dash or in
+// capitals.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// handlerTOTPEnroll starts enrolling an authenticator. It returns the secret,
+// the otpauth:// URI and the same URI as a QR code PNG. Two-factor login only
+// kicks in once a code is confirmed, so an abandoned enrollment is harmless
+// and calling this again starts over with a new secret.
+func (cfg *apiConfig) handlerTOTPEnroll(w http.ResponseWriter, req *http.Request) {
+	type returnVals struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+		QRCode     string `json:"qr_code"`
+	}
+
+	userID := callerOf(req).UserID
+
+	user, err := cfg.db.GetUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start enrollment", err)
+		return
+	}
+
+	started, err := cfg.db.StartTOTPEnrollment(req.Context(), database.StartTOTPEnrollmentParams{
+		UserID: user.ID,
+		Secret: secret,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start enrollment", err)
+		return
+	}
+	if started == 0 {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+
+	uri := totp.URI(totpIssuer, user.Email, secret)
+	code, err := qr.Encode([]byte(uri))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't draw the QR code", err)
+		return
+	}
+	image, err := code.PNG(6)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't draw the QR code", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		Secret:     secret,
+		OTPAuthURI: uri,
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(image),
+	})
+}
+
+// handlerTOTPConfirm finishes enrollment with a code from the authenticator,
+// proving it was set up correctly, and hands out the recovery codes.
+func (cfg *apiConfig) handlerTOTPConfirm(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+	type returnVals struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	userID := callerOf(req).UserID
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't confirm enrollment", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	secret, err := qtx.GetUserTOTPForUpdate(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "No enrollment in progress", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't confirm enrollment", err)
+		return
+	}
+	if secret.ConfirmedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+
+	step, ok := totp.Validate(secret.Secret, params.Code, time.Now())
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Invalid code", nil)
+		return
+	}
+
+	err = qtx.ConfirmUserTOTP(req.Context(), database.ConfirmUserTOTPParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't confirm enrollment", err)
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(req.Context(), qtx, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't confirm enrollment", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't confirm enrollment", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		RecoveryCodes: codes,
+	})
+}
+
+// handlerRecoveryCodesRegenerate replaces the recovery codes, for when they
+// ran out or may have been seen. It needs a current authenticator code, and
+// wrong codes count against the login throttle like they do when logging in.
+func (cfg *apiConfig) handlerRecoveryCodesRegenerate(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+	type returnVals struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	userID := callerOf(req).UserID
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.db.GetUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	throttleKeys := loginThrottleKeys(user.Email, cfg.clientIP(req))
+	lockedUntil, err := cfg.loginLockedUntil(req.Context(), throttleKeys)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create recovery codes", err)
+		return
+	}
+	if !lockedUntil.IsZero() {
+		setRetryAfter(w, lockedUntil)
+		respondWithError(w, http.StatusTooManyRequests, "Too many failed attempts, try again later", nil)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create recovery codes", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = checkSecondFactor(req.Context(), qtx, userID, params.Code, "")
+	if errors.Is(err, errSecondFactorInvalid) {
+		lockedUntil, err := cfg.recordLoginFailure(req.Context(), throttleKeys)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create recovery codes", err)
+			return
+		}
+		setRetryAfter(w, lockedUntil)
+		respondWithError(w, http.StatusForbidden, "Invalid code", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create recovery codes", err)
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(req.Context(), qtx, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create recovery codes", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create recovery codes", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		RecoveryCodes: codes,
+	})
+}
+
+// handlerTwoFactorDisable turns two-factor login off. A stolen access token
+// mustn't be enough for that, so it asks for the password again along with
+// an authenticator or recovery code. Failures count against the login
+// throttle and get one answer, so neither secret can be guessed on its own.
+func (cfg *apiConfig) handlerTwoFactorDisable(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	userID := callerOf(req).UserID
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.db.GetUser(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	throttleKeys := loginThrottleKeys(user.Email, cfg.clientIP(req))
+	lockedUntil, err := cfg.loginLockedUntil(req.Context(), throttleKeys)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+	if !lockedUntil.IsZero() {
+		setRetryAfter(w, lockedUntil)
+		respondWithError(w, http.StatusTooManyRequests, "Too many failed attempts, try again later", nil)
+		return
+	}
+
+	refuse := func() {
+		lockedUntil, err := cfg.recordLoginFailure(req.Context(), throttleKeys)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+			return
+		}
+		setRetryAfter(w, lockedUntil)
+		respondWithError(w, http.StatusForbidden, "Incorrect password or code", nil)
+	}
+
+	match, err := auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+	if !match {
+		refuse()
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = checkSecondFactor(req.Context(), qtx, userID, params.Code, params.RecoveryCode)
+	if errors.Is(err, errSecondFactorInvalid) {
+		refuse()
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+
+	err = qtx.DeleteUserTOTP(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+
+	err = qtx.DeleteRecoveryCodes(req.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerLoginTwoFactor is the second step of logging in: it trades a
+// challenge token from POST /api/login and a code for the usual tokens.
+// Each challenge allows a few wrong codes before it has to be started over
+// with the password, and every wrong code also counts against the account
+// throttle so that starting over doesn't buy unlimited guesses.
+func (cfg *apiConfig) handlerLoginTwoFactor(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(req.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	challengeHash := auth.HashToken(params.ChallengeToken)
+	challenge, err := qtx.GetLoginChallengeForUpdate(req.Context(), challengeHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) || challenge.UsedAt.Valid || time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= maxChallengeAttempts {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired challenge", err)
+		return
+	}
+
+	user, err := qtx.GetUser(req.Context(), challenge.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+
+	throttleKeys := loginThrottleKeys(user.Email, cfg.clientIP(req))
+	lockedUntil, err := cfg.loginLockedUntil(req.Context(), throttleKeys)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+	if !lockedUntil.IsZero() {
+		setRetryAfter(w, lockedUntil)
+		respondWithError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later", nil)
+		return
+	}
+
+	err = checkSecondFactor(req.Context(), qtx, challenge.UserID, params.Code, params.RecoveryCode)
+	if errors.Is(err, errSecondFactorInvalid) {
+		// Nothing was spent, so the only change to keep is the miss.
+		err = qtx.FailLoginChallenge(req.Context(), challengeHash)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+			return
+		}
+		lockedUntil, err := cfg.recordLoginFailure(req.Context(), throttleKeys)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+			return
+		}
+		setRetryAfter(w, lockedUntil)
+		respondWithError(w, http.StatusUnauthorized, "Invalid code", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+
+	err = qtx.UseLoginChallenge(req.Context(), challengeHash)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+
+	err = cfg.db.ClearLoginThrottle(req.Context(), accountThrottleKey(user.Email))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+
+	session, err := cfg.startSession(req.Context(), user, cfg.deviceOf(req))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't log in", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, session)
+}